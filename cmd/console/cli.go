@@ -1,14 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/divilla/gossip-cluster/internal/memlistconf"
+	"github.com/divilla/gossip-cluster/pkg/gossip"
 	"github.com/gookit/gcli/v3"
 	"github.com/hashicorp/memberlist"
 	"go.uber.org/zap"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 )
 
@@ -64,6 +67,25 @@ func cli() {
 		Config:     makeConfig(&opt),
 	})
 
+	app.Add(&gcli.Command{
+		Name:     "transfer",
+		Desc:     "Make the current leader step down in favor of the target node.",
+		Examples: "gc transfer --ip 127.0.0.1 127.0.0.1:8081 3",
+		Flags:    makeFlags(),
+		Func:     makeTransferCommand(logger, &opt),
+		Help:     "Request is sent to any cluster node, followers forward it to the leader.",
+		Config: func(c *gcli.Command) {
+			c.AddArg("node", "Address (ip:port) of any cluster node.", true)
+			c.AddArg("target", "NodeID of the new leader.", true)
+			c.StrVar(&opt.BindIPAddress, &gcli.FlagMeta{
+				Name:     "ip",
+				Desc:     "Address to send the request from.",
+				Shorts:   []string{"i"},
+				Required: false,
+			})
+		},
+	})
+
 	app.Run(nil)
 
 	<-quitCh
@@ -188,6 +210,27 @@ func makeJoinCommand(logger *zap.Logger, opt *options, quitCh chan os.Signal) fu
 	}
 }
 
+func makeTransferCommand(logger *zap.Logger, opt *options) func(*gcli.Command, []string) error {
+	return func(cmd *gcli.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("node address and target NodeID are required")
+		}
+
+		target, err := strconv.ParseUint(args[1], 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid target NodeID '%s': %w", args[1], err)
+		}
+
+		if err = gossip.RequestLeadershipTransfer(opt.BindIPAddress, args[0], uint16(target)); err != nil {
+			return err
+		}
+
+		logger.Info("leadership transfer requested", zap.String("node", args[0]), zap.Uint64("target", target))
+
+		return nil
+	}
+}
+
 func parseOptions(cfg *memberlist.Config, opt *options) {
 	cfg.PushPullInterval = time.Second
 
@@ -30,15 +30,14 @@ func main() {
 	nc.First = true
 	cfg.Nodes[0] = nc
 
-	c1, err := gossip.NewCluster(logger, cfg.Nodes[0], stopCh)
+	c1, err := newCluster(logger, cfg.Nodes[0], stopCh)
 	if err != nil {
 		panic(err)
 	}
-	_, err = gossip.NewCluster(logger, cfg.Nodes[1], stopCh)
-	if err != nil {
+	if _, err = newCluster(logger, cfg.Nodes[1], stopCh); err != nil {
 		panic(err)
 	}
-	if _, err = gossip.NewCluster(logger, cfg.Nodes[2], stopCh); err != nil {
+	if _, err = newCluster(logger, cfg.Nodes[2], stopCh); err != nil {
 		panic(err)
 	}
 
@@ -58,8 +57,21 @@ func main() {
 	fmt.Println("*********************************** Starting up 1 *******************************************")
 	fmt.Println("-----------------------------------------------------------------------------------------------")
 	fmt.Println()
-	c1, err = gossip.NewCluster(logger, cfg.Nodes[0], stopCh)
+	c1, err = newCluster(logger, cfg.Nodes[0], stopCh)
 
 	<-quitCh
 	close(stopCh)
 }
+
+func newCluster(logger *zap.Logger, cfg *gossip.Config, stopCh <-chan struct{}) (*gossip.Cluster, error) {
+	c, err := gossip.NewCluster(logger, cfg, stopCh)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.RegisterWorkerType(dbLoaderType, newDbLoaderFactory(logger, cfg.NodeID)); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"github.com/divilla/gossip-cluster/pkg/gossip"
+	"go.uber.org/zap"
+)
+
+const dbLoaderType = "db_loader"
+
+type (
+	// dbLoader is a demo gossip.Worker which only logs when started or stopped.
+	dbLoader struct {
+		logger   *zap.Logger
+		node     uint16
+		name     string
+		database string
+	}
+)
+
+func newDbLoaderFactory(logger *zap.Logger, node uint16) gossip.WorkerFactory {
+	return func(cfg gossip.WorkerConfig) (gossip.Worker, error) {
+		return &dbLoader{
+			logger:   logger,
+			node:     node,
+			name:     cfg.Name,
+			database: cfg.Settings["database"],
+		}, nil
+	}
+}
+
+func (l *dbLoader) Name() string {
+	return l.name
+}
+
+func (l *dbLoader) Start(ctx context.Context, epoch uint64) error {
+	l.logger.Info("dbLoader.Start()",
+		zap.Uint16("node", l.node),
+		zap.String("worker", l.name),
+		zap.String("database", l.database),
+		zap.Uint64("epoch", epoch))
+	return nil
+}
+
+func (l *dbLoader) Stop(ctx context.Context) error {
+	l.logger.Info("dbLoader.Stop()", zap.Uint16("node", l.node), zap.String("worker", l.name), zap.String("database", l.database))
+	return nil
+}
@@ -10,7 +10,8 @@ import (
 )
 
 type Config struct {
-	Nodes []*gossip.Config `yaml:"nodes"`
+	Nodes   []*gossip.Config      `yaml:"nodes"`
+	Workers []gossip.WorkerConfig `yaml:"workers"`
 }
 
 func New(paths ...string) (*Config, error) {
@@ -31,6 +32,13 @@ func New(paths ...string) (*Config, error) {
 			return nil, fmt.Errorf("yaml.Unmarshal() %w", err)
 		}
 
+		// nodes without own workers share the cluster-wide ones
+		for _, node := range cfg.Nodes {
+			if len(node.Workers) == 0 {
+				node.Workers = cfg.Workers
+			}
+		}
+
 		return cfg, nil
 	}
 
@@ -0,0 +1,161 @@
+package gossip
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	ModuloAssignment         = "modulo"
+	ConsistentHashAssignment = "consistent_hash"
+	RendezvousAssignment     = "rendezvous"
+	StickyAssignment         = "sticky"
+
+	defaultNodeWeight = 1.0
+)
+
+var ErrUnknownAssignmentStrategy = errors.New("unknown assignment strategy")
+
+type (
+	// AssignmentStrategy distributes workers between nodes. Every node computes the assignment on its own,
+	// so Assign must be deterministic: the same nodes & workers must always produce the same result.
+	// Returned map holds assigned workers for each of the nodes.
+	AssignmentStrategy interface {
+		Assign(nodes []AssignmentNode, workers []string) map[uint16][]string
+	}
+
+	// StickyAssignmentStrategy builds the assignment from the previous one. Cluster using it keeps workers
+	// running through rebalance, only workers that moved are stopped & started.
+	StickyAssignmentStrategy interface {
+		AssignmentStrategy
+		Reassign(nodes []AssignmentNode, workers []string, previous map[uint16][]string) map[uint16][]string
+	}
+
+	// AssignmentNode is a node taking part in the assignment, sorted by ID.
+	AssignmentNode struct {
+		ID     uint16
+		Weight float64
+		Tags   []string
+	}
+
+	// ModuloStrategy assigns worker with index i to node with index i % len(nodes).
+	// Almost every worker moves whenever a node joins or leaves.
+	ModuloStrategy struct{}
+)
+
+func newAssignmentStrategy(c *Config) (AssignmentStrategy, error) {
+	if c.Assigner != nil {
+		return c.Assigner, nil
+	}
+
+	switch c.AssignmentStrategy {
+	case "", ModuloAssignment:
+		return &ModuloStrategy{}, nil
+	case ConsistentHashAssignment:
+		return NewConsistentHashStrategy(c.VirtualNodes), nil
+	case RendezvousAssignment:
+		return &RendezvousStrategy{}, nil
+	case StickyAssignment:
+		return &StickyStrategy{}, nil
+	}
+
+	return nil, fmt.Errorf("gossip.newAssignmentStrategy() '%s': %w", c.AssignmentStrategy, ErrUnknownAssignmentStrategy)
+}
+
+func (s *ModuloStrategy) Assign(nodes []AssignmentNode, workers []string) map[uint16][]string {
+	assignment := newAssignment(nodes)
+	if len(nodes) == 0 {
+		return assignment
+	}
+
+	for key, worker := range workers {
+		id := nodes[key%len(nodes)].ID
+		assignment[id] = append(assignment[id], worker)
+	}
+
+	return assignment
+}
+
+// assignTagged assigns workers with Tags only to nodes that have all of them. Workers with the same Tags
+// are assigned by the strategy among their eligible nodes, workers without any eligible node are left out.
+func assignTagged(strategy AssignmentStrategy,
+	nodes []AssignmentNode,
+	workers []WorkerConfig,
+	previous map[uint16][]string,
+) map[uint16][]string {
+	groups := make(map[string][]string)
+	tags := make(map[string][]string)
+	for _, wc := range workers {
+		key := strings.Join(wc.Tags, ",")
+		groups[key] = append(groups[key], wc.Name)
+		tags[key] = wc.Tags
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	assignment := newAssignment(nodes)
+	for _, key := range keys {
+		eligible := make([]AssignmentNode, 0, len(nodes))
+		for _, node := range nodes {
+			if hasTags(node.Tags, tags[key]) {
+				eligible = append(eligible, node)
+			}
+		}
+
+		var group map[uint16][]string
+		if sticky, ok := strategy.(StickyAssignmentStrategy); ok {
+			group = sticky.Reassign(eligible, groups[key], previous)
+		} else {
+			group = strategy.Assign(eligible, groups[key])
+		}
+
+		for _, node := range eligible {
+			assignment[node.ID] = append(assignment[node.ID], group[node.ID]...)
+		}
+	}
+
+	return assignment
+}
+
+func countAssigned(assignment map[uint16][]string) int {
+	var count int
+	for _, workers := range assignment {
+		count += len(workers)
+	}
+
+	return count
+}
+
+// hasTags returns true when all of the required tags are in tags
+func hasTags(tags, required []string) bool {
+	for _, r := range required {
+		found := false
+		for _, tag := range tags {
+			if tag == r {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newAssignment(nodes []AssignmentNode) map[uint16][]string {
+	assignment := make(map[uint16][]string, len(nodes))
+	for _, node := range nodes {
+		assignment[node.ID] = make([]string, 0)
+	}
+
+	return assignment
+}
@@ -0,0 +1,76 @@
+package gossip
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAssignTagged(t *testing.T) {
+	nodes := testNodes(1, 1, 1)
+	nodes[0].Tags = []string{"mysql"}
+	nodes[1].Tags = []string{"mysql", "ssd"}
+
+	workers := []WorkerConfig{
+		{Name: "a"},
+		{Name: "b", Tags: []string{"mysql"}},
+		{Name: "c", Tags: []string{"mysql", "ssd"}},
+		{Name: "d", Tags: []string{"mysql"}},
+		{Name: "e", Tags: []string{"oracle"}},
+		{Name: "f"},
+		{Name: "g"},
+	}
+
+	tests := []struct {
+		name     string
+		strategy AssignmentStrategy
+	}{
+		{name: "modulo", strategy: &ModuloStrategy{}},
+		{name: "consistent hash", strategy: NewConsistentHashStrategy(0)},
+		{name: "rendezvous", strategy: &RendezvousStrategy{}},
+		{name: "sticky", strategy: &StickyStrategy{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment := assignTagged(tt.strategy, nodes, workers, nil)
+			owner := owners(t, assignment)
+
+			if _, ok := owner["e"]; ok {
+				t.Fatalf("worker e without eligible node assigned to node %d", owner["e"])
+			}
+
+			for _, worker := range []string{"b", "d"} {
+				if owner[worker] != 1 && owner[worker] != 2 {
+					t.Fatalf("worker %s tagged mysql assigned to node %d", worker, owner[worker])
+				}
+			}
+
+			if owner["c"] != 2 {
+				t.Fatalf("worker c tagged mysql & ssd assigned to node %d, want 2", owner["c"])
+			}
+
+			if len(owner) != len(workers)-1 || countAssigned(assignment) != len(workers)-1 {
+				t.Fatalf("%d workers assigned, want %d", len(owner), len(workers)-1)
+			}
+
+			if !reflect.DeepEqual(assignment, assignTagged(tt.strategy, nodes, workers, nil)) {
+				t.Fatal("assignment is not deterministic")
+			}
+		})
+	}
+}
+
+func TestAssignTagged_Untagged(t *testing.T) {
+	s := NewConsistentHashStrategy(0)
+	nodes := testNodes(1, 1, 1)
+	names := testWorkers(100)
+
+	workers := make([]WorkerConfig, len(names))
+	for key, name := range names {
+		workers[key] = WorkerConfig{Name: name}
+	}
+
+	if !reflect.DeepEqual(assignTagged(s, nodes, workers, nil), s.Assign(nodes, names)) {
+		t.Fatal("untagged workers are not assigned by the strategy among all nodes")
+	}
+}
@@ -3,10 +3,18 @@ package gossip
 import (
 	"github.com/hashicorp/memberlist"
 	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+const (
+	broadcastQueued int32 = iota
+	broadcastFinished
+	broadcastInvalidated
 )
 
 type (
 	Broadcast struct {
+		state    int32
 		logger   *zap.Logger
 		ml       *memberlist.Memberlist
 		name     string
@@ -42,6 +50,7 @@ func (b *Broadcast) Message() []byte {
 
 func (b *Broadcast) Finished() {
 	b.logger.Info("Broadcast.Finished()")
+	atomic.CompareAndSwapInt32(&b.state, broadcastQueued, broadcastFinished)
 	if b.notifyCh != nil {
 		close(b.notifyCh)
 	}
@@ -50,3 +59,13 @@ func (b *Broadcast) Finished() {
 func (b *Broadcast) Name() string {
 	return b.name
 }
+
+// Invalidated returns true when the broadcast was replaced by a newer one of the same name before it was transmitted
+func (b *Broadcast) Invalidated() bool {
+	return atomic.LoadInt32(&b.state) == broadcastInvalidated
+}
+
+// invalidate marks the queued broadcast replaced, before the queue finishes it
+func (b *Broadcast) invalidate() {
+	atomic.CompareAndSwapInt32(&b.state, broadcastQueued, broadcastInvalidated)
+}
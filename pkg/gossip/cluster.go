@@ -2,67 +2,206 @@ package gossip
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/hashicorp/memberlist"
 	"go.uber.org/zap"
 	"runtime"
+	"sync"
 	"time"
 )
 
+const (
+	messageQueueSize = 256
+
+	assignmentPlanMethod = "assignment_plan"
+	planRequestInterval  = time.Second
+)
+
+var ErrClusterNotReady = errors.New("cluster is not initialized yet")
+
 type (
 	Cluster struct {
-		Config     *Config
-		Memberlist *memberlist.Memberlist
-		State      *StateManager
-		Messenger  *Messenger
-		logger     *zap.Logger
-		joinCh     chan uint16
-		leaveCh    chan uint16
-		stopCh     <-chan struct{}
+		lockMaxTTL    int64
+		Config        *Config
+		Memberlist    *memberlist.Memberlist
+		State         *StateManager
+		Messenger     *Messenger
+		Workers       *WorkerManager
+		KV            *KV
+		CRDT          *CRDTStore
+		queries       *queries
+		syncCounters  *syncCounters
+		lockMu        sync.Mutex
+		ownershipLost bool
+		strategy      AssignmentStrategy
+		logger        *zap.Logger
+		joinCh        chan uint16
+		leaveCh       chan uint16
+		electCh       chan struct{}
+		msgCh         chan []byte
+		stopCh        <-chan struct{}
 	}
 
 	NodeMeta struct {
-		NodeID uint16 `json:"node_id"`
+		NodeID      uint16   `json:"node_id"`
+		Weight      float64  `json:"weight"`
+		Priority    int      `json:"priority"`
+		NeverLeader bool     `json:"never_leader,omitempty"`
+		Tags        []string `json:"tags,omitempty"`
 	}
 
 	FinishFunc func()
 )
 
 func NewCluster(logger *zap.Logger, cfg *Config, stopCh <-chan struct{}) (*Cluster, error) {
-	cluster := &Cluster{
-		Config:  parseDefaults(cfg),
-		logger:  logger,
-		joinCh:  make(chan uint16, 1),
-		leaveCh: make(chan uint16, 1),
-		stopCh:  stopCh,
+	if err := validate(cfg); err != nil {
+		return nil, fmt.Errorf("gossip.NewCluster(): %w", err)
+	}
+
+	cfg = parseDefaults(cfg)
+	strategy, err := newAssignmentStrategy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("gossip.NewCluster(): %w", err)
 	}
 
-	go cluster.init()
+	mlc := newMemberListConfig(cfg)
+	cluster := &Cluster{
+		Config: cfg,
+		State: newStateManager(cfg.Debug,
+			logger,
+			cfg.NodeID,
+			mlc.Name,
+			strategy,
+			cfg.ElectionQuorum,
+			time.Duration(cfg.TombstoneTTLS)*time.Second,
+			time.Duration(cfg.MaxClockSkewMS)*time.Millisecond,
+		),
+		Workers:      newWorkerManager(logger, cfg.NodeID, cfg.Workers),
+		queries:      newQueries(),
+		syncCounters: &syncCounters{},
+		strategy:     strategy,
+		logger:       logger,
+		joinCh:       make(chan uint16, 1),
+		leaveCh:      make(chan uint16, 1),
+		electCh:      make(chan struct{}, 1),
+		msgCh:        make(chan []byte, messageQueueSize),
+		stopCh:       stopCh,
+	}
+	cluster.Messenger = newMessenger(logger, cfg.NodeID, newTlq(func() int {
+		return cluster.Memberlist.NumMembers()
+	}), cluster.msgCh)
+	cluster.KV = newKV(logger,
+		cfg.NodeID,
+		cluster.State.clock,
+		cluster.Messenger,
+		time.Duration(cfg.TombstoneTTLS)*time.Second,
+	)
+	cluster.CRDT = newCRDTStore(logger,
+		cfg.NodeID,
+		cluster.State.clock,
+		cluster.Messenger,
+		time.Duration(cfg.TombstoneTTLS)*time.Second,
+	)
+	cluster.registerHandlers()
+
+	go cluster.init(mlc)
 
 	return cluster, nil
 }
 
-func (c *Cluster) init() {
-	var err error
+// RegisterWorker registers Worker on the local node. Every node of the cluster should register the same workers,
+// each of them is started only on the node it is assigned to.
+func (c *Cluster) RegisterWorker(w Worker) error {
+	return c.Workers.Register(w)
+}
+
+// RegisterWorkerType registers WorkerFactory creating workers of WorkerConfig.Type on the local node.
+func (c *Cluster) RegisterWorkerType(typ string, factory WorkerFactory) error {
+	return c.Workers.RegisterType(typ, factory)
+}
+
+// AddWorker adds worker to the cluster at runtime, change is propagated to all nodes & triggers rebalance.
+func (c *Cluster) AddWorker(wc WorkerConfig) error {
+	if !c.Messenger.Ready() {
+		return ErrClusterNotReady
+	}
+
+	registry, err := c.Workers.Add(wc)
+	if err != nil {
+		return fmt.Errorf("gossip.Cluster.AddWorker(): %w", err)
+	}
+
+	if err = c.Messenger.BroadcastWorkerRegistry(registry); err != nil {
+		return fmt.Errorf("gossip.Cluster.AddWorker(): %w", err)
+	}
+
+	return nil
+}
+
+// RemoveWorker removes worker from the cluster at runtime, change is propagated to all nodes & triggers rebalance.
+func (c *Cluster) RemoveWorker(name string) error {
+	if !c.Messenger.Ready() {
+		return ErrClusterNotReady
+	}
 
-	mlc := newMemberListConfig(c.Config)
-	c.State = newStateManager(c.Config.Debug, c.logger, c.Config.NodeID, mlc.Name, len(c.Config.JoinNodes) == 0)
+	registry, err := c.Workers.Remove(name)
+	if err != nil {
+		return fmt.Errorf("gossip.Cluster.RemoveWorker(): %w", err)
+	}
+
+	if err = c.Messenger.BroadcastWorkerRegistry(registry); err != nil {
+		return fmt.Errorf("gossip.Cluster.RemoveWorker(): %w", err)
+	}
+
+	return nil
+}
 
-	tlq := newTlq(c.Memberlist)
-	nodeMeta := &NodeMeta{NodeID: c.Config.NodeID}
-	if mlc.Delegate, err = newDelegate(c.Config.Debug, c.logger, tlq, nodeMeta, c.State); err != nil {
+// WorkerEpoch returns current owner & ownership epoch of the worker. Owner presenting an older epoch
+// is stale, e.g. it came back from a GC pause or network partition, and must be fenced off.
+func (c *Cluster) WorkerEpoch(name string) (uint16, uint64) {
+	return c.State.WorkerEpoch(name)
+}
+
+func (c *Cluster) init(mlc *memberlist.Config) {
+	var err error
+
+	nodeMeta := &NodeMeta{
+		NodeID:      c.Config.NodeID,
+		Weight:      c.Config.Weight,
+		Priority:    c.Config.LeaderPriority,
+		NeverLeader: c.Config.NeverLeader,
+		Tags:        c.Config.Tags,
+	}
+	c.State.SetNodeMeta(*nodeMeta)
+	mlc.Delegate, err = newDelegate(c.Config.Debug,
+		c.logger,
+		c.Messenger.tlq,
+		nodeMeta,
+		c.State,
+		c.Workers,
+		c.KV,
+		c.CRDT,
+		c.Messenger,
+		c.Config.FullStateSync,
+		c.syncCounters,
+		c.msgCh,
+	)
+	if err != nil {
 		panic(err)
 	}
-	mlc.Events = newEventDelegate(c.Config.Debug, c.logger, mlc.Name, c.joinCh, c.leaveCh)
+	mlc.Events = newEventDelegate(c.Config.Debug, c.logger, mlc.Name, c.State, c.joinCh, c.leaveCh)
 
 	if c.Memberlist, err = memberlist.Create(mlc); err != nil {
 		panic(fmt.Errorf("memberlist.Create() error: %w", err))
 	}
 
-	go c.onJoinOrLeave()
+	c.Messenger.attach(c.Memberlist)
 
-	c.Messenger = newMessenger(c.logger, c.Memberlist, tlq)
+	go c.onJoinOrLeave()
+	go c.onMessage()
+	go c.heartbeat()
 
 	if !c.Config.First {
 		if err = c.join(); err != nil {
@@ -97,8 +236,8 @@ func (c *Cluster) onJoinOrLeave() {
 				ctx, cancel := makeContext(c.Config.AssembleTimeoutS)
 				oldCancel = cancel
 
-				if err = c.stop(ctx, cancel); err != nil {
-					c.logger.Error("gossip.Cluster.elect()", zap.Error(err))
+				if err = c.prepare(ctx, cancel); err != nil {
+					c.logger.Error("gossip.Cluster.prepare()", zap.Error(err))
 					return
 				}
 
@@ -110,6 +249,7 @@ func (c *Cluster) onJoinOrLeave() {
 					return
 				}
 
+				c.State.StartElection()
 				if err = c.elect(ctx, cancel); err != nil {
 					if !errors.Is(err, context.Canceled) {
 						c.logger.Error("gossip.Cluster.elect()", zap.Error(err))
@@ -135,6 +275,8 @@ func (c *Cluster) onJoinOrLeave() {
 				}
 			}()
 		case id = <-c.leaveCh:
+			c.Messenger.forgetPublisher(id)
+
 			if oldCancel != nil {
 				oldCancel()
 			}
@@ -145,13 +287,14 @@ func (c *Cluster) onJoinOrLeave() {
 			go func() {
 				runtime.Gosched()
 
-				if err = c.stop(ctx, cancel); err != nil {
-					c.logger.Error("gossip.Cluster.stop()", zap.Error(err))
+				if err = c.prepare(ctx, cancel); err != nil {
+					c.logger.Error("gossip.Cluster.prepare()", zap.Error(err))
 					return
 				}
 
 				c.State.RemoveNode(id)
 
+				c.State.StartElection()
 				if err = c.elect(ctx, cancel); err != nil {
 					if !errors.Is(err, context.Canceled) {
 						c.logger.Error("gossip.Cluster.elect()", zap.Error(err))
@@ -167,11 +310,217 @@ func (c *Cluster) onJoinOrLeave() {
 
 					return
 				}
+
+				if err = c.start(ctx, cancel); err != nil {
+					if !errors.Is(err, context.Canceled) {
+						c.logger.Error("gossip.Cluster.start()", zap.Error(err))
+					}
+
+					return
+				}
+			}()
+		case <-c.Workers.Changed():
+			if oldCancel != nil {
+				oldCancel()
+			}
+
+			ctx, cancel := makeContext(c.Config.AssembleTimeoutS)
+			oldCancel = cancel
+
+			go func() {
+				runtime.Gosched()
+
+				c.rebalance(ctx, cancel, false)
+			}()
+		case <-c.electCh:
+			if oldCancel != nil {
+				oldCancel()
+			}
+
+			ctx, cancel := makeContext(c.Config.AssembleTimeoutS)
+			oldCancel = cancel
+
+			go func() {
+				runtime.Gosched()
+
+				c.rebalance(ctx, cancel, true)
 			}()
 		}
 	}
 }
 
+// rebalance reassigns workers after WorkerRegistry change, or after the leader of the latest term
+// is elected when reelect is set
+func (c *Cluster) rebalance(ctx context.Context, cancel context.CancelFunc, reelect bool) {
+	var err error
+
+	if err = c.prepare(ctx, cancel); err != nil {
+		c.logger.Error("gossip.Cluster.prepare()", zap.Error(err))
+		return
+	}
+
+	if reelect {
+		if err = c.elect(ctx, cancel); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				c.logger.Error("gossip.Cluster.elect()", zap.Error(err))
+			}
+
+			return
+		}
+	}
+
+	if err = c.assign(ctx, cancel); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			c.logger.Error("gossip.Cluster.assign()", zap.Error(err))
+		}
+
+		return
+	}
+
+	if err = c.start(ctx, cancel); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			c.logger.Error("gossip.Cluster.start()", zap.Error(err))
+		}
+
+		return
+	}
+}
+
+func (c *Cluster) onMessage() {
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case msg := <-c.msgCh:
+			c.Messenger.dispatch(msg)
+		}
+	}
+}
+
+func (c *Cluster) registerHandlers() {
+	handlers := map[string]MessageHandler{
+		workerRegistryMessage:            c.onWorkerRegistry,
+		assignmentPlanMessage:            c.onAssignmentPlan,
+		workerReleaseMessage:             c.onWorkerRelease,
+		heartbeatMessage:                 c.onHeartbeat,
+		leadershipTransferMessage:        c.onLeadershipTransfer,
+		leadershipTransferRequestMessage: c.onLeadershipTransferRequest,
+		queryMessage:                     c.onQuery,
+		queryResponseMessage:             c.onQueryResponse,
+		stateDeltaMessage:                c.onStateDelta,
+		kvMessage:                        c.KV.onEntries,
+		crdtDeltaMessage:                 c.CRDT.onDelta,
+	}
+
+	for typ, handler := range handlers {
+		if err := c.Messenger.Handle(typ, handler); err != nil {
+			panic(err)
+		}
+	}
+
+	if err := c.HandleQuery(WorkersQuery, c.queryWorkers); err != nil {
+		panic(err)
+	}
+
+	c.KV.Watch(LockKeyPrefix, c.onLockRecord)
+
+	rpcHandlers := map[string]RPCHandler{
+		lockAcquireMethod: c.onLockAcquire,
+		lockRenewMethod:   c.onLockRenew,
+		lockReleaseMethod: c.onLockRelease,
+
+		assignmentPlanMethod: c.onPlanRequest,
+	}
+
+	for method, handler := range rpcHandlers {
+		if err := c.Messenger.HandleRPC(method, handler); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (c *Cluster) onWorkerRegistry(msg Envelope) error {
+	var registry WorkerRegistry
+	if err := msg.Decode(&registry); err != nil {
+		return err
+	}
+
+	c.Workers.Merge(registry)
+
+	return nil
+}
+
+func (c *Cluster) onAssignmentPlan(msg Envelope) error {
+	var plan AssignmentPlan
+	if err := msg.Decode(&plan); err != nil {
+		return err
+	}
+
+	c.State.ImportPlan(plan)
+
+	return nil
+}
+
+// requestPlan imports the latest AssignmentPlan of the leader, returns true when LocalNode already works by it
+func (c *Cluster) requestPlan(ctx context.Context) (bool, error) {
+	leader, ok := c.State.Leader()
+	if !ok {
+		return false, ErrNoLeader
+	}
+
+	body, err := c.Messenger.Call(ctx, leader, assignmentPlanMethod, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var plan AssignmentPlan
+	if err = json.Unmarshal(body, &plan); err != nil {
+		return false, err
+	}
+
+	if c.State.IsApplied(plan) {
+		return true, nil
+	}
+	c.State.ImportPlan(plan)
+
+	return false, nil
+}
+
+// onPlanRequest answers the latest AssignmentPlan computed by the leader
+func (c *Cluster) onPlanRequest(_ context.Context, _ uint16, _ json.RawMessage) (interface{}, error) {
+	plan, ok := c.State.LatestPlan()
+	if !ok || !c.State.IsLeader() {
+		return nil, ErrNotLeader
+	}
+
+	return plan, nil
+}
+
+// onHeartbeat renews the leader lease, settled node that missed newer term or newer plan of the leader rebalances
+func (c *Cluster) onHeartbeat(msg Envelope) error {
+	var hb LeaderHeartbeat
+	if err := msg.Decode(&hb); err != nil {
+		return err
+	}
+
+	if c.State.RenewLease(hb) || c.State.HasNewerPlan(hb) {
+		c.reelect()
+	}
+
+	return nil
+}
+
+func (c *Cluster) onWorkerRelease(msg Envelope) error {
+	var release WorkerRelease
+	if err := msg.Decode(&release); err != nil {
+		return err
+	}
+
+	c.Workers.Released(release)
+
+	return nil
+}
+
 func (c *Cluster) join() error {
 	var err error
 
@@ -243,6 +592,8 @@ func (c *Cluster) elect(ctx context.Context, cancel context.CancelFunc) error {
 		}
 	}
 
+	timeout := time.Duration(c.Config.ElectLeaderS) * time.Second
+	startedAt := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
@@ -251,6 +602,14 @@ func (c *Cluster) elect(ctx context.Context, cancel context.CancelFunc) error {
 		default:
 		}
 
+		if time.Since(startedAt) > timeout {
+			term := c.State.RestartElection()
+			startedAt = time.Now()
+			c.logger.Warn("gossip.Cluster.elect(), election timed out, new term started",
+				zap.Uint16("node", c.Config.NodeID),
+				zap.Uint64("term", term))
+		}
+
 		if c.State.ElectLeader() {
 			if err = c.State.Trigger(Elected); err != nil {
 				cancel()
@@ -280,7 +639,36 @@ func (c *Cluster) assign(ctx context.Context, cancel context.CancelFunc) error {
 		}
 	}
 
-	c.State.AssignWorkers()
+	if c.State.IsLeader() {
+		plan := c.State.ComputePlan(c.Workers.Registry().Workers)
+		if err = c.Messenger.SendAssignmentPlan(plan); err != nil {
+			cancel()
+			return fmt.Errorf("gossip.Cluster.assign(): %w", err)
+		}
+	}
+
+	// followers wait for the plan of the current leader & ask the leader for it, when it does not arrive in time,
+	// e.g. it was lost or the leader has no new plan as only the follower is rebalancing
+	requestAt := time.Now().Add(planRequestInterval)
+	for !c.State.ApplyPlan() {
+		if time.Now().After(requestAt) {
+			requestAt = time.Now().Add(planRequestInterval)
+
+			applied, err := c.requestPlan(ctx)
+			if err != nil {
+				c.logger.Debug("gossip.Cluster.requestPlan()", zap.Error(err))
+			} else if applied {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			c.logger.Warn("gossip.Cluster.assign(), context canceled", zap.Error(ctx.Err()))
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
 
 	select {
 	case <-ctx.Done():
@@ -310,6 +698,21 @@ func (c *Cluster) start(ctx context.Context, cancel context.CancelFunc) error {
 		}
 	}
 
+	plan := c.State.AppliedPlan()
+	names := c.State.LocalWorkers()
+	if err = c.release(ctx, plan, names); err != nil {
+		c.logger.Error("gossip.Cluster.release()", zap.Error(err))
+	}
+
+	if err = c.awaitHandoff(ctx, plan); err != nil {
+		c.logger.Warn("gossip.Cluster.awaitHandoff()", zap.Error(err))
+		return err
+	}
+
+	if err = c.Workers.Start(ctx, names, c.State.LocalEpochs()); err != nil {
+		c.logger.Error("gossip.Cluster.start()", zap.Error(err))
+	}
+
 	c.State.StartWorkers()
 
 	select {
@@ -326,6 +729,37 @@ func (c *Cluster) start(ctx context.Context, cancel context.CancelFunc) error {
 	return nil
 }
 
+// prepare brings the local node to Configuring state before workers are reassigned.
+// Sticky assignment keeps workers running, otherwise all of them are stopped.
+func (c *Cluster) prepare(ctx context.Context, cancel context.CancelFunc) error {
+	if c.State.IsSticky() {
+		return c.reconfigure(ctx, cancel)
+	}
+
+	return c.stop(ctx, cancel)
+}
+
+func (c *Cluster) reconfigure(ctx context.Context, cancel context.CancelFunc) error {
+	var err error
+
+	if c.State.CurrentState() == Configuring {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		c.logger.Warn("gossip.Cluster.reconfigure()", zap.Error(ctx.Err()))
+		return ctx.Err()
+	default:
+		if err = c.State.Trigger(Reconfigure); err != nil {
+			cancel()
+			return fmt.Errorf("gossip.Cluster.reconfigure(): %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (c *Cluster) stop(ctx context.Context, cancel context.CancelFunc) error {
 	var err error
 
@@ -345,6 +779,10 @@ func (c *Cluster) stop(ctx context.Context, cancel context.CancelFunc) error {
 		}
 	}
 
+	if err = c.Workers.Stop(ctx); err != nil {
+		c.logger.Error("gossip.Cluster.stop()", zap.Error(err))
+	}
+
 	c.State.StopWorkers()
 
 	select {
@@ -393,11 +831,9 @@ func newMemberListConfig(c *Config) *memberlist.Config {
 	return mlc
 }
 
-func newTlq(ml *memberlist.Memberlist) *memberlist.TransmitLimitedQueue {
+func newTlq(numNodes func() int) *memberlist.TransmitLimitedQueue {
 	return &memberlist.TransmitLimitedQueue{
-		NumNodes: func() int {
-			return ml.NumMembers()
-		},
+		NumNodes:       numNodes,
 		RetransmitMult: 3,
 	}
 }
@@ -1,10 +1,17 @@
 package gossip
 
+import "errors"
+
+var ErrConfigNodeID = errors.New("node_id must be greater than 0")
+
 const (
 	defaultMinNodesNum      = 3
 	defaultJoinTimeoutS     = 10
 	defaultAssembleTimeoutS = 30
 	defaultElectLeaderS     = 30
+	defaultHandoffTimeoutS  = 15
+	defaultTombstoneTTLS    = 300
+	defaultMaxClockSkewMS   = 60000
 )
 
 type (
@@ -15,6 +22,7 @@ type (
 		AdvertiseAddr      string `yaml:"advertise_addr"`
 		AdvertisePort      int    `yaml:"advertise_port"`
 		PushPullIntervalMS int    `yaml:"push_pull_interval_ms"`
+		FullStateSync      bool   `yaml:"full_state_sync"`
 
 		First            bool     `yaml:"first"`
 		JoinNodes        []string `yaml:"join_nodes"`
@@ -22,11 +30,33 @@ type (
 		JoinTimeoutS     int      `yaml:"join_timeout_s"`
 		AssembleTimeoutS int      `yaml:"assemble_timeout_s"`
 		ElectLeaderS     int      `yaml:"elect_leader_s"`
+		ElectionQuorum   int      `yaml:"election_quorum"`
+		LeaderPriority   int      `yaml:"leader_priority"`
+		NeverLeader      bool     `yaml:"never_leader"`
+		HandoffTimeoutS  int      `yaml:"handoff_timeout_s"`
+		TombstoneTTLS    int      `yaml:"tombstone_ttl_s"`
+		MaxClockSkewMS   int      `yaml:"max_clock_skew_ms"`
+
+		Weight             float64            `yaml:"weight"`
+		Tags               []string           `yaml:"tags"`
+		Workers            []WorkerConfig     `yaml:"workers"`
+		AssignmentStrategy string             `yaml:"assignment_strategy"`
+		VirtualNodes       int                `yaml:"virtual_nodes"`
+		Assigner           AssignmentStrategy `yaml:"-"`
 
 		Debug bool `yaml:"debug"`
 	}
 )
 
+// validate returns error for the settings without a default, zero NodeID is reserved for no leader & no owner
+func validate(c *Config) error {
+	if c.NodeID == 0 {
+		return ErrConfigNodeID
+	}
+
+	return nil
+}
+
 func parseDefaults(c *Config) *Config {
 	if c.JoinNodesNum == 0 {
 		c.JoinNodesNum = defaultMinNodesNum
@@ -44,5 +74,25 @@ func parseDefaults(c *Config) *Config {
 		c.ElectLeaderS = defaultElectLeaderS
 	}
 
+	if c.HandoffTimeoutS == 0 {
+		c.HandoffTimeoutS = defaultHandoffTimeoutS
+	}
+
+	if c.TombstoneTTLS == 0 {
+		c.TombstoneTTLS = defaultTombstoneTTLS
+	}
+
+	if c.MaxClockSkewMS == 0 {
+		c.MaxClockSkewMS = defaultMaxClockSkewMS
+	}
+
+	if c.Weight <= 0 {
+		c.Weight = defaultNodeWeight
+	}
+
+	if c.VirtualNodes == 0 {
+		c.VirtualNodes = defaultVirtualNodes
+	}
+
 	return c
 }
@@ -0,0 +1,86 @@
+package gossip
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"sort"
+	"strconv"
+)
+
+const defaultVirtualNodes = 128
+
+type (
+	// ConsistentHashStrategy places every node on a hash ring VirtualNodes times, worker is assigned
+	// to the first node found clockwise from the worker's hash. Membership change moves only ~1/N of workers.
+	ConsistentHashStrategy struct {
+		VirtualNodes int
+	}
+
+	ringPoint struct {
+		hash uint64
+		node uint16
+	}
+)
+
+func NewConsistentHashStrategy(virtualNodes int) *ConsistentHashStrategy {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+
+	return &ConsistentHashStrategy{
+		VirtualNodes: virtualNodes,
+	}
+}
+
+func (s *ConsistentHashStrategy) Assign(nodes []AssignmentNode, workers []string) map[uint16][]string {
+	assignment := newAssignment(nodes)
+	if len(nodes) == 0 {
+		return assignment
+	}
+
+	ring := s.ring(nodes)
+	for _, worker := range workers {
+		h := hashKey(worker)
+		i := sort.Search(len(ring), func(i int) bool {
+			return ring[i].hash >= h
+		})
+		if i == len(ring) {
+			i = 0
+		}
+
+		id := ring[i].node
+		assignment[id] = append(assignment[id], worker)
+	}
+
+	return assignment
+}
+
+func (s *ConsistentHashStrategy) ring(nodes []AssignmentNode) []ringPoint {
+	ring := make([]ringPoint, 0, len(nodes)*s.VirtualNodes)
+	for _, node := range nodes {
+		prefix := strconv.Itoa(int(node.ID)) + "#"
+		for i := 0; i < s.VirtualNodes; i++ {
+			ring = append(ring, ringPoint{
+				hash: hashKey(prefix + strconv.Itoa(i)),
+				node: node.ID,
+			})
+		}
+	}
+
+	sort.Slice(ring,
+		func(i, j int) bool {
+			if ring[i].hash == ring[j].hash {
+				return ring[i].node < ring[j].node
+			}
+
+			return ring[i].hash < ring[j].hash
+		},
+	)
+
+	return ring
+}
+
+func hashKey(key string) uint64 {
+	sum := sha1.Sum([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
@@ -0,0 +1,120 @@
+package gossip
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func testNodes(weights ...float64) []AssignmentNode {
+	nodes := make([]AssignmentNode, len(weights))
+	for key, weight := range weights {
+		nodes[key] = AssignmentNode{
+			ID:     uint16(key + 1),
+			Weight: weight,
+		}
+	}
+
+	return nodes
+}
+
+func testWorkers(n int) []string {
+	workers := make([]string, n)
+	for key := range workers {
+		workers[key] = "worker-" + strconv.Itoa(key)
+	}
+
+	return workers
+}
+
+// owners maps workers to nodes of the assignment & fails the test when a worker is assigned more than once
+func owners(t *testing.T, assignment map[uint16][]string) map[string]uint16 {
+	t.Helper()
+
+	owners := make(map[string]uint16)
+	for id, workers := range assignment {
+		for _, worker := range workers {
+			if previous, ok := owners[worker]; ok {
+				t.Fatalf("worker %s assigned to nodes %d & %d", worker, previous, id)
+			}
+			owners[worker] = id
+		}
+	}
+
+	return owners
+}
+
+// moved returns workers whose owner differs between the assignments
+func moved(t *testing.T, before, after map[uint16][]string) map[string]uint16 {
+	t.Helper()
+
+	from, to := owners(t, before), owners(t, after)
+	moved := make(map[string]uint16)
+	for worker, id := range to {
+		if from[worker] != id {
+			moved[worker] = id
+		}
+	}
+
+	return moved
+}
+
+func TestConsistentHashStrategy_Assign(t *testing.T) {
+	s := NewConsistentHashStrategy(0)
+	workers := testWorkers(1000)
+
+	tests := []struct {
+		name  string
+		nodes []AssignmentNode
+	}{
+		{name: "no nodes", nodes: nil},
+		{name: "single node", nodes: testNodes(1)},
+		{name: "three nodes", nodes: testNodes(1, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment := s.Assign(tt.nodes, workers)
+			if len(assignment) != len(tt.nodes) {
+				t.Fatalf("assignment has %d nodes, want %d", len(assignment), len(tt.nodes))
+			}
+
+			if len(tt.nodes) > 0 && len(owners(t, assignment)) != len(workers) {
+				t.Fatalf("%d workers assigned, want %d", len(owners(t, assignment)), len(workers))
+			}
+
+			if !reflect.DeepEqual(assignment, s.Assign(tt.nodes, workers)) {
+				t.Fatal("assignment is not deterministic")
+			}
+		})
+	}
+}
+
+func TestConsistentHashStrategy_Membership(t *testing.T) {
+	s := NewConsistentHashStrategy(0)
+	workers := testWorkers(3000)
+	three := s.Assign(testNodes(1, 1, 1), workers)
+	four := s.Assign(testNodes(1, 1, 1, 1), workers)
+
+	t.Run("join", func(t *testing.T) {
+		m := moved(t, three, four)
+		for worker, id := range m {
+			if id != 4 {
+				t.Fatalf("worker %s moved to node %d, only the joined node 4 may take workers", worker, id)
+			}
+		}
+
+		if share := float64(len(m)) / float64(len(workers)); share < 0.15 || share > 0.35 {
+			t.Fatalf("%.2f of workers moved, want ~1/4", share)
+		}
+	})
+
+	t.Run("leave", func(t *testing.T) {
+		from := owners(t, four)
+		for worker := range moved(t, four, three) {
+			if from[worker] != 4 {
+				t.Fatalf("worker %s of node %d moved, only workers of the left node 4 may move", worker, from[worker])
+			}
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package gossip
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+type (
+	// GCounter is grow-only counter, every replica increments its own slot & merge keeps the highest value
+	// of each slot, so concurrent increments of all nodes add up.
+	GCounter struct {
+		name   string
+		store  *CRDTStore
+		counts gCounts
+		hash   stateHash
+		mu     sync.RWMutex
+	}
+
+	// PNCounter is counter that can be incremented & decremented, it is a pair of grow-only counters
+	PNCounter struct {
+		name  string
+		store *CRDTStore
+		pn    pnCounts
+		hash  stateHash
+		mu    sync.RWMutex
+	}
+
+	// gCounts holds slots of the grow-only counter by replica. Slots are never dropped, a replica partitioned
+	// for any time could gossip the dropped slot back & it would be counted twice.
+	gCounts map[string]uint64
+
+	pnCounts struct {
+		P gCounts `json:"p"`
+		N gCounts `json:"n"`
+	}
+)
+
+func newGCounter(name string, store *CRDTStore) *GCounter {
+	return &GCounter{
+		name:   name,
+		store:  store,
+		counts: make(gCounts),
+	}
+}
+
+// Inc increments the counter by n & broadcasts the local slot
+func (c *GCounter) Inc(n uint64) error {
+	c.mu.Lock()
+	delta := c.counts.inc(c.store.replica, n)
+	c.hash.reset()
+	c.mu.Unlock()
+
+	return c.store.broadcast(c.name, gCounterType, "", delta)
+}
+
+// Value returns sum of all slots
+func (c *GCounter) Value() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.counts.value()
+}
+
+func (c *GCounter) typ() string {
+	return gCounterType
+}
+
+func (c *GCounter) state() interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.counts.copy()
+}
+
+func (c *GCounter) merge(data json.RawMessage) error {
+	var counts gCounts
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts.merge(counts)
+	c.hash.reset()
+
+	return nil
+}
+
+func (c *GCounter) digest() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hash.of(c.counts)
+}
+
+func newPNCounter(name string, store *CRDTStore) *PNCounter {
+	return &PNCounter{
+		name:  name,
+		store: store,
+		pn: pnCounts{
+			P: make(gCounts),
+			N: make(gCounts),
+		},
+	}
+}
+
+// Add adds n to the counter, n may be negative, & broadcasts the local slots
+func (c *PNCounter) Add(n int64) error {
+	replica := c.store.replica
+
+	c.mu.Lock()
+	var delta pnCounts
+	if n >= 0 {
+		delta = pnCounts{
+			P: c.pn.P.inc(replica, uint64(n)),
+			N: c.pn.N.inc(replica, 0),
+		}
+	} else {
+		delta = pnCounts{
+			P: c.pn.P.inc(replica, 0),
+			N: c.pn.N.inc(replica, uint64(-n)),
+		}
+	}
+	c.hash.reset()
+	c.mu.Unlock()
+
+	return c.store.broadcast(c.name, pnCounterType, "", delta)
+}
+
+// Value returns sum of increments minus sum of decrements
+func (c *PNCounter) Value() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return int64(c.pn.P.value()) - int64(c.pn.N.value())
+}
+
+func (c *PNCounter) typ() string {
+	return pnCounterType
+}
+
+func (c *PNCounter) state() interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return pnCounts{
+		P: c.pn.P.copy(),
+		N: c.pn.N.copy(),
+	}
+}
+
+func (c *PNCounter) merge(data json.RawMessage) error {
+	var pn pnCounts
+	if err := json.Unmarshal(data, &pn); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pn.P.merge(pn.P)
+	c.pn.N.merge(pn.N)
+	c.hash.reset()
+
+	return nil
+}
+
+func (c *PNCounter) digest() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hash.of(c.pn)
+}
+
+// inc increments slot of the replica by n & returns delta with the slot.
+// Empty slots are not created, as merge never copies them.
+func (g gCounts) inc(replica string, n uint64) gCounts {
+	if n > 0 {
+		g[replica] += n
+	}
+
+	delta := make(gCounts)
+	if count, ok := g[replica]; ok {
+		delta[replica] = count
+	}
+
+	return delta
+}
+
+func (g gCounts) value() uint64 {
+	var sum uint64
+	for _, count := range g {
+		sum += count
+	}
+
+	return sum
+}
+
+func (g gCounts) merge(o gCounts) {
+	for slot, count := range o {
+		if count > g[slot] {
+			g[slot] = count
+		}
+	}
+}
+
+func (g gCounts) copy() gCounts {
+	counts := make(gCounts, len(g))
+	for slot, count := range g {
+		counts[slot] = count
+	}
+
+	return counts
+}
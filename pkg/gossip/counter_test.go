@@ -0,0 +1,112 @@
+package gossip
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGCounts_merge(t *testing.T) {
+	states := []gCounts{
+		{"1.a": 3, "2.a": 1},
+		{"1.a": 1, "2.a": 4, "3.a": 2},
+		{"3.a": 5},
+	}
+	want := gCounts{"1.a": 3, "2.a": 4, "3.a": 5}
+
+	tests := []struct {
+		name  string
+		order []int
+	}{
+		{name: "in order", order: []int{0, 1, 2}},
+		{name: "reversed", order: []int{2, 1, 0}},
+		{name: "shuffled", order: []int{1, 2, 0}},
+		{name: "duplicated", order: []int{0, 1, 1, 2, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(gCounts)
+			for _, key := range tt.order {
+				got.merge(states[key])
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("merge() = %v, want %v", got, want)
+			}
+
+			if got.value() != 12 {
+				t.Fatalf("value() = %d, want 12", got.value())
+			}
+		})
+	}
+}
+
+func TestGCounts_inc(t *testing.T) {
+	g := make(gCounts)
+	if delta := g.inc("1.a", 0); len(g) != 0 || len(delta) != 0 {
+		t.Fatalf("inc() by 0 created slot %v, delta %v", g, delta)
+	}
+
+	_ = g.inc("1.a", 2)
+	if delta := g.inc("1.a", 3); !reflect.DeepEqual(delta, gCounts{"1.a": 5}) {
+		t.Fatalf("inc() delta = %v, want the whole slot", delta)
+	}
+}
+
+func TestGCounter(t *testing.T) {
+	a, b, c := testStore(1), testStore(2), testStore(3)
+	ca, _ := a.GCounter("c")
+	cb, _ := b.GCounter("c")
+	cc, _ := c.GCounter("c")
+
+	_ = ca.Inc(1)
+	_ = cb.Inc(2)
+	// state of the replica partitioned before its later increments
+	stale := cb.state()
+	_ = cb.Inc(3)
+	_ = cc.Inc(4)
+
+	mergeAll(t, ca, cb, cc)
+	mergeAll(t, cc, cb, ca)
+	assertConverged(t, ca, cb, cc)
+
+	for _, counter := range []*GCounter{ca, cb, cc} {
+		if counter.Value() != 10 {
+			t.Fatalf("Value() = %d, want 10", counter.Value())
+		}
+	}
+
+	// restarted node counts in the new slot, slot of the previous incarnation is kept
+	restarted, _ := testStore(2).GCounter("c")
+	syncCRDT(t, ca, restarted)
+	_ = restarted.Inc(5)
+	mergeAll(t, ca, restarted)
+	if ca.Value() != 15 {
+		t.Fatalf("Value() after restart = %d, want 15", ca.Value())
+	}
+
+	// state of the replica partitioned for any time is not counted twice
+	ca.counts.merge(stale.(gCounts))
+	if ca.Value() != 15 {
+		t.Fatalf("Value() after merging stale state = %d, want 15", ca.Value())
+	}
+}
+
+func TestPNCounter(t *testing.T) {
+	a, b := testStore(1), testStore(2)
+	ca, _ := a.PNCounter("c")
+	cb, _ := b.PNCounter("c")
+
+	_ = ca.Add(5)
+	_ = ca.Add(-2)
+	_ = cb.Add(-7)
+	_ = cb.Add(0)
+
+	mergeAll(t, ca, cb)
+	mergeAll(t, ca, cb)
+	assertConverged(t, ca, cb)
+
+	if ca.Value() != -4 || cb.Value() != -4 {
+		t.Fatalf("Value() = %d & %d, want -4", ca.Value(), cb.Value())
+	}
+}
@@ -0,0 +1,293 @@
+package gossip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	gCounterType  = "g_counter"
+	pnCounterType = "pn_counter"
+	orSetType     = "or_set"
+	lwwMapType    = "lww_map"
+)
+
+var (
+	ErrCRDTNameEmpty    = errors.New("crdt name is empty")
+	ErrCRDTTypeMismatch = errors.New("crdt name is used by another type")
+	ErrCRDTTypeUnknown  = errors.New("crdt type is unknown")
+)
+
+type (
+	// CRDTStore holds named conflict-free replicated data types shared by all nodes of the cluster.
+	// Local updates are broadcast as deltas, push/pull state sync exchanges state hashes & repairs data types
+	// that differ with their full states, so replicas converge without coordination regardless of the order
+	// changes arrive in. Data types are created on first use, on the local node or when their state arrives
+	// from a peer. Deleted map keys are kept for deletedTTL.
+	CRDTStore struct {
+		logger     *zap.Logger
+		replica    string
+		clock      *Clock
+		messenger  *Messenger
+		deletedTTL time.Duration
+		items      map[string]crdt
+		rwm        sync.RWMutex
+	}
+
+	// CRDTState is the state, or delta, of the named data type exchanged between nodes
+	CRDTState struct {
+		Name  string          `json:"name"`
+		Type  string          `json:"type"`
+		State json.RawMessage `json:"state"`
+	}
+
+	crdt interface {
+		typ() string
+		state() interface{}
+		merge(data json.RawMessage) error
+		digest() uint64
+	}
+
+	// pruner is implemented by data types that drop deleted data after the TTL
+	pruner interface {
+		prune(ttl time.Duration)
+	}
+
+	// stateHash caches hash of the data type state until it changes
+	stateHash struct {
+		valid bool
+		sum   uint64
+	}
+)
+
+// newCRDTStore creates the store of the node incarnation, replica identifies counter slots & set dots
+// of the incarnation, so that updates made after restart never overlap with the ones made before it.
+func newCRDTStore(logger *zap.Logger,
+	localNodeID uint16,
+	clock *Clock,
+	messenger *Messenger,
+	deletedTTL time.Duration,
+) *CRDTStore {
+	return &CRDTStore{
+		logger:     logger,
+		replica:    replicaPrefix(localNodeID) + strconv.FormatUint(newGeneration(), 36),
+		clock:      clock,
+		messenger:  messenger,
+		deletedTTL: deletedTTL,
+		items:      make(map[string]crdt),
+	}
+}
+
+// GCounter returns grow-only counter of the name
+func (s *CRDTStore) GCounter(name string) (*GCounter, error) {
+	c, err := s.get(name, gCounterType)
+	if err != nil {
+		return nil, fmt.Errorf("gossip.CRDTStore.GCounter(): %w", err)
+	}
+
+	return c.(*GCounter), nil
+}
+
+// PNCounter returns counter of the name that can be incremented & decremented
+func (s *CRDTStore) PNCounter(name string) (*PNCounter, error) {
+	c, err := s.get(name, pnCounterType)
+	if err != nil {
+		return nil, fmt.Errorf("gossip.CRDTStore.PNCounter(): %w", err)
+	}
+
+	return c.(*PNCounter), nil
+}
+
+// ORSet returns observed-remove set of the name
+func (s *CRDTStore) ORSet(name string) (*ORSet, error) {
+	c, err := s.get(name, orSetType)
+	if err != nil {
+		return nil, fmt.Errorf("gossip.CRDTStore.ORSet(): %w", err)
+	}
+
+	return c.(*ORSet), nil
+}
+
+// LWWMap returns last-writer-wins map of the name
+func (s *CRDTStore) LWWMap(name string) (*LWWMap, error) {
+	c, err := s.get(name, lwwMapType)
+	if err != nil {
+		return nil, fmt.Errorf("gossip.CRDTStore.LWWMap(): %w", err)
+	}
+
+	return c.(*LWWMap), nil
+}
+
+// States returns full states of all data types
+func (s *CRDTStore) States() []CRDTState {
+	return s.Delta(nil)
+}
+
+// Digest returns state hashes of all data types
+func (s *CRDTStore) Digest() map[string]uint64 {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	digest := make(map[string]uint64, len(s.items))
+	for name, c := range s.items {
+		s.prune(c)
+		digest[name] = c.digest()
+	}
+
+	return digest
+}
+
+// Delta returns full states of data types with hashes different from the digest, or missing from it
+func (s *CRDTStore) Delta(digest map[string]uint64) []CRDTState {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	states := make([]CRDTState, 0)
+	for name, c := range s.items {
+		s.prune(c)
+		if sum, ok := digest[name]; ok && sum == c.digest() {
+			continue
+		}
+
+		data, err := json.Marshal(c.state())
+		if err != nil {
+			s.logger.Error("gossip.CRDTStore.Delta()", zap.String("name", name), zap.Error(err))
+			continue
+		}
+
+		states = append(states, CRDTState{
+			Name:  name,
+			Type:  c.typ(),
+			State: data,
+		})
+	}
+
+	return states
+}
+
+// Merge merges states, or deltas, received from other nodes
+func (s *CRDTStore) Merge(states []CRDTState) {
+	for _, cs := range states {
+		c, err := s.get(cs.Name, cs.Type)
+		if err == nil {
+			err = c.merge(cs.State)
+		}
+
+		if err != nil {
+			s.logger.Warn("gossip.CRDTStore.Merge()",
+				zap.String("name", cs.Name),
+				zap.String("type", cs.Type),
+				zap.Error(err))
+		}
+	}
+}
+
+func (s *CRDTStore) get(name, typ string) (crdt, error) {
+	if name == "" {
+		return nil, ErrCRDTNameEmpty
+	}
+
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	if c, ok := s.items[name]; ok {
+		if c.typ() != typ {
+			return nil, fmt.Errorf("'%s' is %s: %w", name, c.typ(), ErrCRDTTypeMismatch)
+		}
+
+		return c, nil
+	}
+
+	var c crdt
+	switch typ {
+	case gCounterType:
+		c = newGCounter(name, s)
+	case pnCounterType:
+		c = newPNCounter(name, s)
+	case orSetType:
+		c = newORSet(name, s)
+	case lwwMapType:
+		c = newLWWMap(name, s)
+	default:
+		return nil, fmt.Errorf("'%s': %w", typ, ErrCRDTTypeUnknown)
+	}
+	s.items[name] = c
+
+	return c, nil
+}
+
+// broadcast gossips delta of the local update, queued delta of the same name & key is invalidated,
+// key is empty for data types whose every delta supersedes the previous one
+func (s *CRDTStore) broadcast(name, typ, key string, delta interface{}) error {
+	if !s.messenger.Ready() {
+		return nil
+	}
+
+	data, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+
+	msg, err := newEnvelope(crdtDeltaMessage, s.messenger.localNodeID, []CRDTState{{
+		Name:  name,
+		Type:  typ,
+		State: data,
+	}})
+	if err != nil {
+		return err
+	}
+	s.messenger.queue(crdtDeltaMessage+":"+name+"/"+key, msg, nil)
+
+	return nil
+}
+
+func (s *CRDTStore) prune(c crdt) {
+	if p, ok := c.(pruner); ok {
+		p.prune(s.deletedTTL)
+	}
+}
+
+func (s *CRDTStore) onDelta(msg Envelope) error {
+	var states []CRDTState
+	if err := msg.Decode(&states); err != nil {
+		return err
+	}
+
+	s.Merge(states)
+
+	return nil
+}
+
+// of returns hash of the state JSON, maps are marshalled with sorted keys so equal states hash equally
+func (h *stateHash) of(state interface{}) uint64 {
+	if h.valid {
+		return h.sum
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return 0
+	}
+
+	f := fnv.New64a()
+	_, _ = f.Write(data)
+	h.sum = f.Sum64()
+	h.valid = true
+
+	return h.sum
+}
+
+func (h *stateHash) reset() {
+	h.valid = false
+}
+
+// replicaPrefix prefixes replicas of all incarnations of the node
+func replicaPrefix(nodeID uint16) string {
+	return strconv.Itoa(int(nodeID)) + "."
+}
@@ -0,0 +1,77 @@
+package gossip
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+// testStore returns CRDTStore of the node incarnation that is not a member of any cluster, updates are not broadcast
+func testStore(nodeID uint16) *CRDTStore {
+	return newCRDTStore(zap.NewNop(), nodeID, newClock(zap.NewNop(), time.Minute), newMessenger(zap.NewNop(), nodeID, nil, nil), time.Minute)
+}
+
+// syncCRDT merges full state of the data type from into to, as push/pull state sync does
+func syncCRDT(t *testing.T, from, to crdt) {
+	t.Helper()
+
+	data, err := json.Marshal(from.state())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = to.merge(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mergeAll merges full states of all replicas into each of them in the given order
+func mergeAll(t *testing.T, replicas ...crdt) {
+	t.Helper()
+
+	for _, to := range replicas {
+		for _, from := range replicas {
+			if from != to {
+				syncCRDT(t, from, to)
+			}
+		}
+	}
+}
+
+// assertConverged fails the test when digests of replicas differ
+func assertConverged(t *testing.T, replicas ...crdt) {
+	t.Helper()
+
+	for _, r := range replicas[1:] {
+		if r.digest() != replicas[0].digest() {
+			t.Fatalf("replicas did not converge: %+v != %+v", r.state(), replicas[0].state())
+		}
+	}
+}
+
+func TestCRDTStore_Delta(t *testing.T) {
+	a, b := testStore(1), testStore(2)
+	ca, _ := a.GCounter("c")
+	_ = ca.Inc(3)
+	sa, _ := a.ORSet("s")
+	_ = sa.Add("x")
+
+	if delta := a.Delta(b.Digest()); len(delta) != 2 {
+		t.Fatalf("Delta() for the empty peer has %d states, want 2", len(delta))
+	}
+
+	b.Merge(a.Delta(b.Digest()))
+	if delta := a.Delta(b.Digest()); len(delta) != 0 {
+		t.Fatalf("Delta() after merge has %d states, want 0", len(delta))
+	}
+
+	cb, _ := b.GCounter("c")
+	if cb.Value() != 3 {
+		t.Fatalf("merged counter = %d, want 3", cb.Value())
+	}
+
+	if _, err := b.ORSet("c"); err == nil {
+		t.Fatal("ORSet() of the counter name succeeded, want ErrCRDTTypeMismatch")
+	}
+}
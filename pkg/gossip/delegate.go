@@ -4,18 +4,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/memberlist"
-	"github.com/tidwall/gjson"
 	"go.uber.org/zap"
 )
 
 type (
 	Delegate struct {
-		debug  bool
-		logger *zap.Logger
-		tlq    *memberlist.TransmitLimitedQueue
-		nm     *NodeMeta
-		nmb    []byte
-		State  *StateManager
+		debug        bool
+		logger       *zap.Logger
+		tlq          *memberlist.TransmitLimitedQueue
+		nm           *NodeMeta
+		nmb          []byte
+		msgCh        chan<- []byte
+		State        *StateManager
+		Workers      *WorkerManager
+		KV           *KV
+		CRDT         *CRDTStore
+		Messenger    *Messenger
+		fullSync     bool
+		syncCounters *syncCounters
 	}
 
 	Update struct {
@@ -29,12 +35,26 @@ func newDelegate(debug bool,
 	tlq *memberlist.TransmitLimitedQueue,
 	nm *NodeMeta,
 	sm *StateManager,
+	wm *WorkerManager,
+	kv *KV,
+	crdt *CRDTStore,
+	m *Messenger,
+	fullSync bool,
+	sc *syncCounters,
+	msgCh chan<- []byte,
 ) (*Delegate, error) {
 	d := &Delegate{
-		debug:  debug,
-		logger: logger,
-		tlq:    tlq,
-		State:  sm,
+		debug:        debug,
+		logger:       logger,
+		tlq:          tlq,
+		msgCh:        msgCh,
+		State:        sm,
+		Workers:      wm,
+		KV:           kv,
+		CRDT:         crdt,
+		Messenger:    m,
+		fullSync:     fullSync,
+		syncCounters: sc,
 	}
 	if err := d.setNodeMeta(nm); err != nil {
 		return nil, err
@@ -62,13 +82,26 @@ func (d *Delegate) NodeMeta(limit int) []byte {
 // so would block the entire UDP packet receive loop. Additionally, the byte
 // slice may be modified after the call returns, so it should be copied if needed
 func (d *Delegate) NotifyMsg(b []byte) {
-	d.logger.Info("Delegate.NotifyMsg()", zap.String("b", string(b)))
+	if d.debug {
+		d.logger.Info("gossip.Delegate.NotifyMsg()",
+			zap.String("localNode.Name", d.State.localNodeName),
+			zap.ByteString("b", b))
+	}
 
 	if len(b) == 0 {
 		return
 	}
 
-	fmt.Println("NotifyMsg", string(b))
+	msg := make([]byte, len(b))
+	copy(msg, b)
+
+	select {
+	case d.msgCh <- msg:
+	default:
+		d.logger.Warn("gossip.Delegate.NotifyMsg(), message queue full, message dropped",
+			zap.String("localNode.Name", d.State.localNodeName),
+			zap.ByteString("b", b))
+	}
 }
 
 // GetBroadcasts is called when user data messages can be broadcast.
@@ -78,21 +111,7 @@ func (d *Delegate) NotifyMsg(b []byte) {
 // the limit. Care should be taken that this method does not block,
 // since doing so would block the entire UDP packet receive loop.
 func (d *Delegate) GetBroadcasts(overhead, limit int) [][]byte {
-	broadcasts := d.tlq.GetBroadcasts(overhead, limit)
-
-	for _, data := range broadcasts {
-		fmt.Println(string(data))
-		method := gjson.GetBytes(data, "method").String()
-		switch method {
-		case "select_leader":
-			var slm SelectLeaderMessage
-			if err := json.Unmarshal(data, &slm); err != nil {
-				panic(err)
-			}
-		}
-	}
-
-	return broadcasts
+	return d.tlq.GetBroadcasts(overhead, limit)
 }
 
 // LocalState is used for a TCP Push/Pull. This is sent to
@@ -103,25 +122,22 @@ func (d *Delegate) LocalState(join bool) []byte {
 	if d.debug {
 		d.logger.Info("gossip.Delegate.LocalState()",
 			zap.String("localNode.Name", d.State.localNodeName),
-			zap.Bool("join", true))
-	}
-
-	if join {
-		return nil
+			zap.Bool("join", join))
 	}
 
-	jsonBytes, err := json.Marshal(d.State.LocalState())
+	state := d.syncState()
+	jsonBytes, err := json.Marshal(state)
 	if err != nil {
 		d.logger.Fatal("gossip.Delegate.LocalState() json.Marshal() error",
 			zap.String("localNode.Name", d.State.localNodeName),
 			zap.Error(err))
 	}
+	d.countSync(state, len(jsonBytes))
 
 	if d.debug {
-		jb, _ := json.Marshal(d.State.state.Nodes)
 		d.logger.Info("gossip.Delegate.LocalState()",
 			zap.String("localNode.Name", d.State.localNodeName),
-			zap.ByteString("state", jb))
+			zap.ByteString("state", jsonBytes))
 	}
 
 	return jsonBytes
@@ -140,15 +156,19 @@ func (d *Delegate) MergeRemoteState(buf []byte, join bool) {
 		)
 	}
 
-	if join || len(buf) == 0 {
+	if len(buf) == 0 {
 		return
 	}
 
-	var state map[uint16]NodeState
+	var state SyncState
 	if err := json.Unmarshal(buf, &state); err != nil {
-		panic(err)
+		d.logger.Error("gossip.Delegate.MergeRemoteState() json.Unmarshal()",
+			zap.String("localNode.Name", d.State.localNodeName),
+			zap.Error(err))
+		return
 	}
-	d.State.ImportState(state)
+
+	d.mergeSyncState(state)
 }
 
 func (d *Delegate) setNodeMeta(nm *NodeMeta) error {
@@ -0,0 +1,138 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"sync/atomic"
+)
+
+var (
+	ErrBroadcastNotConfirmed = errors.New("broadcast was not confirmed by all nodes")
+	ErrBroadcastInvalidated  = errors.New("broadcast was invalidated by a newer publication")
+)
+
+type (
+	BroadcastAck struct {
+		ID uint64 `json:"id"`
+	}
+)
+
+// BroadcastAndWait publishes data to the topic like Publish & returns once the publication was transmitted
+// the number of times configured by the retransmit multiplier. ErrBroadcastInvalidated is returned when
+// it was invalidated by a newer publication of the same TopicLatest topic first.
+// Without other members of the cluster there is nothing to transmit, it returns right away.
+func (m *Messenger) BroadcastAndWait(ctx context.Context, topic string, data []byte) error {
+	ml := m.memberlist()
+	if ml == nil {
+		return fmt.Errorf("gossip.Messenger.BroadcastAndWait() '%s': %w", topic, ErrClusterNotReady)
+	}
+
+	var notifyCh chan struct{}
+	if len(m.remoteNodeIDs(ml)) > 0 {
+		notifyCh = make(chan struct{})
+	}
+
+	bc, err := m.publish(topic, data, 0, notifyCh)
+	if err != nil {
+		return fmt.Errorf("gossip.Messenger.BroadcastAndWait() '%s': %w", topic, err)
+	}
+	if notifyCh == nil {
+		return nil
+	}
+	defer m.forget(bc)
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("gossip.Messenger.BroadcastAndWait() '%s': %w", topic, ctx.Err())
+	case <-notifyCh:
+		if bc.Invalidated() {
+			return fmt.Errorf("gossip.Messenger.BroadcastAndWait() '%s': %w", topic, ErrBroadcastInvalidated)
+		}
+
+		return nil
+	}
+}
+
+// BroadcastWithAck publishes data to the topic & waits until every other member of the cluster acknowledges
+// it was received. Publication is never invalidated, regardless of TopicMode. When ctx is done first,
+// NodeIDs of the nodes that did not confirm are returned with ErrBroadcastNotConfirmed.
+func (m *Messenger) BroadcastWithAck(ctx context.Context, topic string, data []byte) ([]uint16, error) {
+	ml := m.memberlist()
+	if ml == nil {
+		return nil, fmt.Errorf("gossip.Messenger.BroadcastWithAck() '%s': %w", topic, ErrClusterNotReady)
+	}
+
+	pending := make(map[uint16]bool)
+	for _, id := range m.remoteNodeIDs(ml) {
+		pending[id] = true
+	}
+
+	id := atomic.AddUint64(&m.callID, 1)
+	ackCh := make(chan uint16, len(pending))
+	m.rwm.Lock()
+	m.acks[id] = ackCh
+	m.rwm.Unlock()
+
+	defer func() {
+		m.rwm.Lock()
+		delete(m.acks, id)
+		m.rwm.Unlock()
+	}()
+
+	if _, err := m.publish(topic, data, id, nil); err != nil {
+		return nil, fmt.Errorf("gossip.Messenger.BroadcastWithAck() '%s': %w", topic, err)
+	}
+
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			missing := make([]uint16, 0, len(pending))
+			for nodeID := range pending {
+				missing = append(missing, nodeID)
+			}
+			sort.Slice(missing, func(i, j int) bool {
+				return missing[i] < missing[j]
+			})
+
+			return missing, fmt.Errorf("gossip.Messenger.BroadcastWithAck() '%s' %v: %w", topic, missing, ErrBroadcastNotConfirmed)
+		case nodeID := <-ackCh:
+			delete(pending, nodeID)
+		}
+	}
+
+	return nil, nil
+}
+
+// acknowledge confirms to the publisher that its publication was received
+func (m *Messenger) acknowledge(publisher uint16, id uint64) {
+	if err := m.SendMessage(publisher, broadcastAckMessage, BroadcastAck{ID: id}); err != nil {
+		m.logger.Warn("gossip.Messenger.acknowledge()",
+			zap.Uint16("publisher", publisher),
+			zap.Error(err))
+	}
+}
+
+func (m *Messenger) onBroadcastAck(msg Envelope) error {
+	var ack BroadcastAck
+	if err := msg.Decode(&ack); err != nil {
+		return err
+	}
+
+	m.rwm.RLock()
+	ackCh, ok := m.acks[ack.ID]
+	m.rwm.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	select {
+	case ackCh <- msg.Sender:
+	default:
+	}
+
+	return nil
+}
@@ -15,16 +15,18 @@ type (
 		debug         bool
 		logger        *zap.Logger
 		localNodeName string
+		state         *StateManager
 		joinCh        chan uint16
 		leaveCh       chan uint16
 	}
 )
 
-func newEventDelegate(debug bool, logger *zap.Logger, lnn string, joinCh, leaveCh chan uint16) *EventDelegate {
+func newEventDelegate(debug bool, logger *zap.Logger, lnn string, sm *StateManager, joinCh, leaveCh chan uint16) *EventDelegate {
 	return &EventDelegate{
 		debug:         debug,
 		logger:        logger,
 		localNodeName: lnn,
+		state:         sm,
 		joinCh:        joinCh,
 		leaveCh:       leaveCh,
 	}
@@ -56,6 +58,7 @@ func (d *EventDelegate) NotifyJoin(node *memberlist.Node) {
 			zap.ByteString("node.Meta", node.Meta))
 	}
 
+	d.state.SetNodeMeta(nodeMeta)
 	d.joinCh <- nodeMeta.NodeID
 }
 
@@ -78,5 +81,14 @@ func (d *EventDelegate) NotifyLeave(node *memberlist.Node) {
 // updated, usually involving the metadata. The Node argument
 // must not be modified.
 func (d *EventDelegate) NotifyUpdate(node *memberlist.Node) {
-	return
+	var nodeMeta NodeMeta
+	if err := json.Unmarshal(node.Meta, &nodeMeta); err != nil {
+		d.logger.Error("gossip.NotifyUpdate() json.Unmarshal()",
+			zap.String("localNode.Name", d.localNodeName),
+			zap.String("node.Name", node.Name),
+			zap.ByteString("node.Meta", node.Meta))
+		return
+	}
+
+	d.state.SetNodeMeta(nodeMeta)
 }
@@ -0,0 +1,161 @@
+package gossip
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+type (
+	// WorkerRelease acknowledges that the node stopped workers it no longer owns under AssignmentPlan Version.
+	WorkerRelease struct {
+		NodeID  uint16   `json:"node_id"`
+		Version uint64   `json:"version"`
+		Workers []string `json:"workers"`
+	}
+)
+
+// Released records WorkerRelease acknowledgement received from the previous owner
+func (m *WorkerManager) Released(release WorkerRelease) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	for _, worker := range release.Workers {
+		nodes, ok := m.released[worker]
+		if !ok {
+			nodes = make(map[uint16]uint64)
+			m.released[worker] = nodes
+		}
+
+		if release.Version > nodes[release.NodeID] {
+			nodes[release.NodeID] = release.Version
+		}
+	}
+}
+
+// IsReleased returns true when node acknowledged releasing worker under AssignmentPlan version or later one
+func (m *WorkerManager) IsReleased(worker string, nodeID uint16, version uint64) bool {
+	m.rwm.RLock()
+	defer m.rwm.RUnlock()
+
+	return m.released[worker][nodeID] >= version
+}
+
+// release stops local workers moved to other nodes by the applied AssignmentPlan & acknowledges it to the cluster
+func (c *Cluster) release(ctx context.Context, plan AssignmentPlan, names []string) error {
+	if err := c.Workers.Release(ctx, names); err != nil {
+		return err
+	}
+
+	released := plan.releasedBy(c.Config.NodeID)
+	if len(released) == 0 {
+		return nil
+	}
+
+	return c.Messenger.SendWorkerRelease(WorkerRelease{
+		NodeID:  c.Config.NodeID,
+		Version: plan.Version,
+		Workers: released,
+	})
+}
+
+// awaitHandoff waits until previous owners of workers moved to the local node acknowledge releasing them,
+// or until their ownership leases have run out. Previous owner that is no longer a member is waited for as well,
+// it may be only partitioned & keep running workers until its own lease expires. Returns early only if context is done.
+func (c *Cluster) awaitHandoff(ctx context.Context, plan AssignmentPlan) error {
+	deadline := time.Now().Add(c.handoffTimeout())
+	for _, worker := range plan.Assignments[c.Config.NodeID] {
+		previous, ok := plan.Previous[worker]
+		if !ok || previous == c.Config.NodeID {
+			continue
+		}
+
+		for !c.Workers.IsReleased(worker, previous, plan.Version) {
+			if time.Now().After(deadline) {
+				c.logger.Warn("gossip.Cluster.awaitHandoff(), release not acknowledged, lease expired",
+					zap.String("worker", worker),
+					zap.Uint16("previous", previous),
+					zap.Uint64("version", plan.Version))
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	return nil
+}
+
+// guardOwnership stops local workers once the ownership lease of LocalNode expires, so that the node
+// cut off from the leader does not keep running workers reassigned to other nodes. Workers are started again
+// when the lease is renewed & the node is working.
+func (c *Cluster) guardOwnership() {
+	if !c.State.OwnershipExpired(c.ownershipLease()) {
+		if c.ownershipLost && c.State.CurrentState() == Working {
+			c.ownershipLost = false
+			if err := c.Workers.Start(context.Background(), c.State.LocalWorkers(), c.State.LocalEpochs()); err != nil {
+				c.logger.Error("gossip.Cluster.guardOwnership()", zap.Error(err))
+			}
+		}
+
+		return
+	}
+
+	if running := c.Workers.Running(); len(running) > 0 {
+		c.logger.Warn("gossip.Cluster.guardOwnership(), ownership lease expired, stopping workers",
+			zap.Uint16("node", c.Config.NodeID),
+			zap.Strings("workers", running))
+
+		c.ownershipLost = true
+		if err := c.Workers.Stop(context.Background()); err != nil {
+			c.logger.Error("gossip.Cluster.guardOwnership()", zap.Error(err))
+		}
+	}
+}
+
+// ownershipLease is renewed by applying AssignmentPlan & by heartbeats of the leader confirming it,
+// it lasts as long as the lease of the leader
+func (c *Cluster) ownershipLease() time.Duration {
+	return time.Duration(c.Config.ElectLeaderS) * time.Second
+}
+
+// handoffTimeout is HandoffTimeoutS, at least twice the ownership lease, which covers heartbeats delayed by gossip
+// & the interval the lease is checked at, so that unacknowledged previous owner has provably stopped its workers
+func (c *Cluster) handoffTimeout() time.Duration {
+	timeout := time.Duration(c.Config.HandoffTimeoutS) * time.Second
+	if lease := 2 * c.ownershipLease(); timeout < lease {
+		return lease
+	}
+
+	return timeout
+}
+
+// OwnershipExpired returns true when LocalNode has not applied AssignmentPlan nor received heartbeat of the leader
+// confirming it for the lease duration
+func (s *StateManager) OwnershipExpired(lease time.Duration) bool {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	return time.Since(s.ownedAt) > lease
+}
+
+// releasedBy returns workers moved away from the node by the plan
+func (p AssignmentPlan) releasedBy(nodeID uint16) []string {
+	owned := make(map[string]bool)
+	for _, worker := range p.Assignments[nodeID] {
+		owned[worker] = true
+	}
+
+	released := make([]string, 0)
+	for worker, previous := range p.Previous {
+		if previous == nodeID && !owned[worker] {
+			released = append(released, worker)
+		}
+	}
+
+	return released
+}
@@ -0,0 +1,97 @@
+package gossip
+
+import (
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	hlcLogicalBits = 16
+	hlcLogicalMask = 1<<hlcLogicalBits - 1
+)
+
+type (
+	// HLC is a hybrid logical clock timestamp: upper 48 bits hold wall time in milliseconds, lower 16 bits
+	// the logical counter ordering events within the same millisecond. Timestamps compare as integers,
+	// never go backwards on a node & are always greater than timestamps the node has seen from other nodes,
+	// so that last-writer-wins merging does not depend on clock skew between hosts.
+	HLC uint64
+
+	// Clock issues HLC timestamps of the local node, remote timestamps more than maxSkew ahead
+	// of the local wall clock are clamped, so that a single host with a wrong clock can not push
+	// clocks of all nodes into the future
+	Clock struct {
+		logger  *zap.Logger
+		last    HLC
+		maxSkew time.Duration
+		now     func() time.Time
+		mu      sync.Mutex
+	}
+)
+
+func newClock(logger *zap.Logger, maxSkew time.Duration) *Clock {
+	return &Clock{
+		logger:  logger,
+		maxSkew: maxSkew,
+		now:     time.Now,
+	}
+}
+
+// Now returns HLC of a local event
+func (c *Clock) Now() HLC {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last = c.last.next(newHLC(c.now()))
+
+	return c.last
+}
+
+// Update moves the clock past HLC received from another node & returns the current one.
+// Remote HLC ahead of the local wall clock more than maxSkew moves the clock only up to maxSkew.
+func (c *Clock) Update(remote HLC) HLC {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if limit := newHLC(c.now().Add(c.maxSkew)); remote > limit {
+		c.logger.Warn("gossip.Clock.Update(), remote clock ahead of the local wall clock more than max skew, clamped",
+			zap.Time("remote", remote.Wall()),
+			zap.Duration("maxSkew", c.maxSkew))
+		remote = limit
+	}
+
+	if remote > c.last {
+		c.last = remote
+	}
+
+	return c.last
+}
+
+// next returns HLC following h, physical time of the host is used when it is ahead
+func (h HLC) next(physical HLC) HLC {
+	if physical > h {
+		return physical
+	}
+
+	return h + 1
+}
+
+// Wall returns wall time part of the timestamp
+func (h HLC) Wall() time.Time {
+	return time.UnixMilli(int64(h >> hlcLogicalBits)).UTC()
+}
+
+// Logical returns logical counter part of the timestamp
+func (h HLC) Logical() uint16 {
+	return uint16(h & hlcLogicalMask)
+}
+
+// expired returns true when wall time of the timestamp is older than ttl
+func (h HLC) expired(ttl time.Duration) bool {
+	return time.Since(h.Wall()) > ttl
+}
+
+func newHLC(t time.Time) HLC {
+	return HLC(t.UnixMilli()) << hlcLogicalBits
+}
@@ -0,0 +1,109 @@
+package gossip
+
+import (
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+// testClock returns Clock reading wall time from *wall, remote clocks may be at most a minute ahead
+func testClock(wall *time.Time) *Clock {
+	return &Clock{
+		logger:  zap.NewNop(),
+		maxSkew: time.Minute,
+		now: func() time.Time {
+			return *wall
+		},
+	}
+}
+
+func TestHLC_next(t *testing.T) {
+	base := newHLC(time.UnixMilli(1_000_000))
+
+	tests := []struct {
+		name     string
+		h        HLC
+		physical HLC
+		want     HLC
+	}{
+		{name: "physical time ahead", h: base, physical: base + 1<<hlcLogicalBits, want: base + 1<<hlcLogicalBits},
+		{name: "same millisecond", h: base, physical: base, want: base + 1},
+		{name: "physical time behind", h: base + 5, physical: base - 1<<hlcLogicalBits, want: base + 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.h.next(tt.physical); got != tt.want {
+				t.Fatalf("next() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClock_Now(t *testing.T) {
+	wall := time.UnixMilli(1_000_000)
+	c := testClock(&wall)
+
+	first := c.Now()
+	if first.Wall() != wall.UTC() || first.Logical() != 0 {
+		t.Fatalf("Now() = %v/%d, want %v/0", first.Wall(), first.Logical(), wall.UTC())
+	}
+
+	second := c.Now()
+	if second <= first || second.Logical() != 1 {
+		t.Fatalf("Now() in the same millisecond = %d, want %d", second, first+1)
+	}
+
+	wall = wall.Add(-time.Second)
+	if third := c.Now(); third <= second {
+		t.Fatalf("Now() after wall clock went back = %d, want > %d", third, second)
+	}
+
+	wall = wall.Add(2 * time.Second)
+	if fourth := c.Now(); fourth.Wall() != wall.UTC() || fourth.Logical() != 0 {
+		t.Fatalf("Now() after wall clock caught up = %v/%d, want %v/0", fourth.Wall(), fourth.Logical(), wall.UTC())
+	}
+}
+
+func TestClock_Update(t *testing.T) {
+	wall := time.UnixMilli(1_000_000)
+	c := testClock(&wall)
+	local := c.Now()
+
+	if got := c.Update(local - 10); got != local {
+		t.Fatalf("Update() with older remote = %d, want %d", got, local)
+	}
+
+	remote := local + 3<<hlcLogicalBits + 7
+	if got := c.Update(remote); got != remote {
+		t.Fatalf("Update() with newer remote = %d, want %d", got, remote)
+	}
+
+	if got := c.Now(); got <= remote {
+		t.Fatalf("Now() after Update() = %d, want > %d", got, remote)
+	}
+}
+
+func TestClock_Update_skew(t *testing.T) {
+	wall := time.UnixMilli(1_000_000)
+	limit := newHLC(wall.Add(time.Minute))
+
+	tests := []struct {
+		name   string
+		remote HLC
+		want   HLC
+	}{
+		{name: "within max skew", remote: limit - 1, want: limit - 1},
+		{name: "at max skew", remote: limit, want: limit},
+		{name: "ahead of max skew", remote: newHLC(wall.Add(time.Hour)), want: limit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testClock(&wall)
+			if got := c.Update(tt.remote); got != tt.want {
+				t.Fatalf("Update() = %v, want %v", got.Wall(), tt.want.Wall())
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package gossip
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKVKeyEmpty    = errors.New("key is empty")
+	ErrKVKeyNotFound = errors.New("key not found")
+)
+
+type (
+	// KV is eventually consistent key/value store replicated to all nodes of the cluster, meant for small
+	// configuration & progress values. Changes are broadcast & repaired on push/pull state sync.
+	// Every key is last-writer-wins register, entry with higher Version wins, NodeID of the writer breaks ties.
+	// Deleted keys are kept as tombstones for Config.TombstoneTTLS, so that the delete is not undone by a peer
+	// that has not seen it yet.
+	KV struct {
+		logger      *zap.Logger
+		localNodeID uint16
+		clock       *Clock
+		messenger   *Messenger
+		deletedTTL  time.Duration
+		entries     map[string]KVEntry
+		watchID     uint64
+		watchers    []kvWatcher
+		rwm         sync.RWMutex
+		pending     []KVEntry
+		notifying   bool
+		notifyMu    sync.Mutex
+	}
+
+	// KVEntry is the value of the key written by NodeID, Version is HLC of the write
+	KVEntry struct {
+		Key     string `json:"key"`
+		Value   []byte `json:"value,omitempty"`
+		Deleted bool   `json:"deleted,omitempty"`
+		Version HLC    `json:"version"`
+		NodeID  uint16 `json:"node_id"`
+	}
+
+	// KVWatcher receives changed entries, local or remote, Deleted is set when the key was deleted.
+	// Watchers are called one at a time in the order of changes, by the goroutine that made the first change
+	// not yet delivered, changes made while watchers run are delivered after them. Watchers must not block.
+	KVWatcher func(entry KVEntry)
+
+	kvWatcher struct {
+		id      uint64
+		prefix  string
+		watcher KVWatcher
+	}
+)
+
+func newKV(logger *zap.Logger, localNodeID uint16, clock *Clock, messenger *Messenger, deletedTTL time.Duration) *KV {
+	return &KV{
+		logger:      logger,
+		localNodeID: localNodeID,
+		clock:       clock,
+		messenger:   messenger,
+		deletedTTL:  deletedTTL,
+		entries:     make(map[string]KVEntry),
+	}
+}
+
+// Put sets value of the key & gossips it to all nodes
+func (k *KV) Put(key string, value []byte) error {
+	if key == "" {
+		return ErrKVKeyEmpty
+	}
+
+	if err := k.write(KVEntry{Key: key, Value: value}); err != nil {
+		return fmt.Errorf("gossip.KV.Put() '%s': %w", key, err)
+	}
+
+	return nil
+}
+
+// Get returns entry of the key, false when the key is not set or was deleted
+func (k *KV) Get(key string) (KVEntry, bool) {
+	k.rwm.RLock()
+	defer k.rwm.RUnlock()
+
+	entry, ok := k.entries[key]
+	if !ok || entry.Deleted {
+		return KVEntry{}, false
+	}
+
+	return entry, true
+}
+
+// Delete deletes the key on all nodes
+func (k *KV) Delete(key string) error {
+	if _, ok := k.Get(key); !ok {
+		return fmt.Errorf("gossip.KV.Delete() '%s': %w", key, ErrKVKeyNotFound)
+	}
+
+	if err := k.write(KVEntry{Key: key, Deleted: true}); err != nil {
+		return fmt.Errorf("gossip.KV.Delete() '%s': %w", key, err)
+	}
+
+	return nil
+}
+
+// List returns entries of keys with the prefix sorted by key, empty prefix lists all keys
+func (k *KV) List(prefix string) []KVEntry {
+	k.rwm.RLock()
+	defer k.rwm.RUnlock()
+
+	entries := make([]KVEntry, 0)
+	for key, entry := range k.entries {
+		if !entry.Deleted && strings.HasPrefix(key, prefix) {
+			entries = append(entries, entry)
+		}
+	}
+	sortKVEntries(entries)
+
+	return entries
+}
+
+// Watch registers watcher of keys with the prefix. Returned function cancels the watch.
+func (k *KV) Watch(prefix string, watcher KVWatcher) func() {
+	k.rwm.Lock()
+	defer k.rwm.Unlock()
+
+	k.watchID++
+	id := k.watchID
+	k.watchers = append(k.watchers, kvWatcher{
+		id:      id,
+		prefix:  prefix,
+		watcher: watcher,
+	})
+
+	return func() {
+		k.unwatch(id)
+	}
+}
+
+// Merge applies entries received from other nodes, entries older than the local ones are ignored
+func (k *KV) Merge(entries []KVEntry) {
+	changed := make([]KVEntry, 0, len(entries))
+
+	k.rwm.Lock()
+	for _, entry := range entries {
+		k.clock.Update(entry.Version)
+
+		if current, ok := k.entries[entry.Key]; ok && !entry.newerThan(current) {
+			continue
+		}
+		k.entries[entry.Key] = entry
+		changed = append(changed, entry)
+	}
+	k.rwm.Unlock()
+
+	k.notify(changed...)
+}
+
+// Entries returns all entries including deleted ones
+func (k *KV) Entries() []KVEntry {
+	k.rwm.Lock()
+	defer k.rwm.Unlock()
+
+	k.pruneDeleted()
+
+	entries := make([]KVEntry, 0, len(k.entries))
+	for _, entry := range k.entries {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Digest returns versions of all keys including deleted ones
+func (k *KV) Digest() map[string]HLC {
+	k.rwm.Lock()
+	defer k.rwm.Unlock()
+
+	k.pruneDeleted()
+
+	versions := make(map[string]HLC, len(k.entries))
+	for key, entry := range k.entries {
+		versions[key] = entry.Version
+	}
+
+	return versions
+}
+
+// Delta returns entries with versions different from the digest, or missing from it
+func (k *KV) Delta(digest map[string]HLC) []KVEntry {
+	k.rwm.RLock()
+	defer k.rwm.RUnlock()
+
+	entries := make([]KVEntry, 0)
+	for key, entry := range k.entries {
+		if version, ok := digest[key]; !ok || entry.Version != version {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// write stores the local entry, its Version is taken under the lock, so that it is newer than any merged entry
+func (k *KV) write(entry KVEntry) error {
+	k.rwm.Lock()
+	entry.Version = k.clock.Now()
+	entry.NodeID = k.localNodeID
+	k.entries[entry.Key] = entry
+	k.rwm.Unlock()
+
+	k.notify(entry)
+
+	if !k.messenger.Ready() {
+		return nil
+	}
+
+	data, err := newEnvelope(kvMessage, k.localNodeID, []KVEntry{entry})
+	if err != nil {
+		return err
+	}
+	k.messenger.queue(kvMessage+":"+entry.Key, data, nil)
+
+	return nil
+}
+
+func (k *KV) onEntries(msg Envelope) error {
+	var entries []KVEntry
+	if err := msg.Decode(&entries); err != nil {
+		return err
+	}
+
+	k.Merge(entries)
+
+	return nil
+}
+
+// notify queues changed entries & delivers them to watchers, unless another goroutine is already delivering,
+// then it delivers them after its own entries, so that watchers are never called concurrently
+func (k *KV) notify(entries ...KVEntry) {
+	k.notifyMu.Lock()
+	k.pending = append(k.pending, entries...)
+	if k.notifying {
+		k.notifyMu.Unlock()
+		return
+	}
+	k.notifying = true
+
+	for len(k.pending) > 0 {
+		entry := k.pending[0]
+		k.pending = k.pending[1:]
+		k.notifyMu.Unlock()
+
+		for _, watcher := range k.watchersOf(entry.Key) {
+			watcher(entry)
+		}
+
+		k.notifyMu.Lock()
+	}
+
+	k.pending = nil
+	k.notifying = false
+	k.notifyMu.Unlock()
+}
+
+func (k *KV) watchersOf(key string) []KVWatcher {
+	k.rwm.RLock()
+	defer k.rwm.RUnlock()
+
+	watchers := make([]KVWatcher, 0, len(k.watchers))
+	for _, w := range k.watchers {
+		if strings.HasPrefix(key, w.prefix) {
+			watchers = append(watchers, w.watcher)
+		}
+	}
+
+	return watchers
+}
+
+func (k *KV) unwatch(id uint64) {
+	k.rwm.Lock()
+	defer k.rwm.Unlock()
+
+	for key, w := range k.watchers {
+		if w.id == id {
+			k.watchers = append(k.watchers[:key], k.watchers[key+1:]...)
+			return
+		}
+	}
+}
+
+// pruneDeleted drops tombstones of deleted keys after the TTL from their Version
+func (k *KV) pruneDeleted() {
+	for key, entry := range k.entries {
+		if entry.Deleted && time.Since(entry.Version.Wall()) > k.deletedTTL {
+			delete(k.entries, key)
+		}
+	}
+}
+
+// newerThan returns true when e should replace o
+func (e KVEntry) newerThan(o KVEntry) bool {
+	if e.Version != o.Version {
+		return e.Version > o.Version
+	}
+
+	return e.NodeID > o.NodeID
+}
+
+func sortKVEntries(entries []KVEntry) {
+	sort.Slice(entries,
+		func(i, j int) bool {
+			return entries[i].Key < entries[j].Key
+		},
+	)
+}
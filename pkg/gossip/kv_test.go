@@ -0,0 +1,71 @@
+package gossip
+
+import (
+	"go.uber.org/zap"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKVEntry_newerThan(t *testing.T) {
+	tests := []struct {
+		name string
+		e    KVEntry
+		o    KVEntry
+		want bool
+	}{
+		{name: "higher version", e: KVEntry{Version: 2, NodeID: 1}, o: KVEntry{Version: 1, NodeID: 2}, want: true},
+		{name: "lower version", e: KVEntry{Version: 1, NodeID: 2}, o: KVEntry{Version: 2, NodeID: 1}, want: false},
+		{name: "tie, bigger node", e: KVEntry{Version: 1, NodeID: 2}, o: KVEntry{Version: 1, NodeID: 1}, want: true},
+		{name: "tie, smaller node", e: KVEntry{Version: 1, NodeID: 1}, o: KVEntry{Version: 1, NodeID: 2}, want: false},
+		{name: "same entry", e: KVEntry{Version: 1, NodeID: 1}, o: KVEntry{Version: 1, NodeID: 1}, want: false},
+		{name: "delete wins by version", e: KVEntry{Deleted: true, Version: 3}, o: KVEntry{Value: []byte("v"), Version: 2}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.e.newerThan(tt.o); got != tt.want {
+				t.Fatalf("newerThan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKV_Watch(t *testing.T) {
+	kv := newKV(zap.NewNop(), 1, newClock(zap.NewNop(), time.Minute), newMessenger(zap.NewNop(), 1, nil, nil), time.Minute)
+
+	var running, calls int32
+	kv.Watch("k", func(entry KVEntry) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			t.Error("watchers called concurrently")
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&calls, 1)
+
+		// write from the watcher is delivered after it returns
+		if entry.Key == "k0" {
+			_ = kv.Put("k-watched", nil)
+		}
+		atomic.AddInt32(&running, -1)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				_ = kv.Put("k"+strconv.Itoa(i), nil)
+				return
+			}
+			kv.Merge([]KVEntry{{Key: "k" + strconv.Itoa(i), Version: 1, NodeID: 2}})
+		}(i)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 5 {
+		t.Fatalf("watcher called %d times, want 5", got)
+	}
+}
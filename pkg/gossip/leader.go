@@ -0,0 +1,81 @@
+package gossip
+
+import "time"
+
+type (
+	// LeaderChange is delivered to LeaderChanges subscribers whenever the agreed leader changes,
+	// zero Leader means the cluster lost its leader, e.g. it left or its lease expired.
+	LeaderChange struct {
+		Leader   uint16 `json:"leader"`
+		Previous uint16 `json:"previous"`
+		Term     uint64 `json:"term"`
+	}
+)
+
+// Leader returns the leader agreed by quorum in the latest election, false when there is none
+func (c *Cluster) Leader() (uint16, bool) {
+	return c.State.Leader()
+}
+
+// LeaderChanges returns new channel receiving LeaderChange whenever the agreed leader changes.
+// Slow subscribers receive only the latest change, the channel is never closed.
+func (c *Cluster) LeaderChanges() <-chan LeaderChange {
+	return c.State.LeaderChanges()
+}
+
+// Leader returns the leader agreed by quorum in the latest election, false when there is none
+func (s *StateManager) Leader() (uint16, bool) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	return s.leader.Leader, s.leader.Leader != 0
+}
+
+// LeaderSince returns the agreed leader & the time LocalNode learned it took office
+func (s *StateManager) LeaderSince() (uint16, time.Time) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	return s.leader.Leader, s.leaderSince
+}
+
+// LeaderChanges subscribes to changes of the agreed leader
+func (s *StateManager) LeaderChanges() <-chan LeaderChange {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	ch := make(chan LeaderChange, 1)
+	s.leaderSubs = append(s.leaderSubs, ch)
+
+	return ch
+}
+
+// setLeader records the agreed leader & notifies subscribers when it changed.
+// Leadership transfer of the term is finished by the election & no longer gossiped.
+func (s *StateManager) setLeader(id uint16, term uint64) {
+	if ns := s.LocalNodeState(); id != 0 && ns.Transfer != nil && ns.Transfer.Term <= term {
+		ns.Transfer = nil
+		s.setLocalNodeState(ns)
+	}
+
+	if s.leader.Leader == id {
+		s.leader.Term = term
+		return
+	}
+
+	s.leader = LeaderChange{
+		Leader:   id,
+		Previous: s.leader.Leader,
+		Term:     term,
+	}
+	s.leaderSince = time.Now()
+
+	for _, ch := range s.leaderSubs {
+		// replace undelivered change with the latest one
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- s.leader
+	}
+}
@@ -0,0 +1,179 @@
+package gossip
+
+import (
+	"go.uber.org/zap"
+	"time"
+)
+
+type (
+	// LeaderHeartbeat renews the lease of the leader elected in Term & ownership leases of the nodes
+	// that applied its AssignmentPlan of Version Plan.
+	LeaderHeartbeat struct {
+		Leader uint16 `json:"leader"`
+		Term   uint64 `json:"term"`
+		Plan   uint64 `json:"plan"`
+	}
+)
+
+// Heartbeat returns LeaderHeartbeat when LocalNode is the leader settled in its term, the leader renews
+// its own ownership lease with it
+func (s *StateManager) Heartbeat() (LeaderHeartbeat, bool) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	ns := s.LocalNodeState()
+	if ns.Leader != s.localNodeID || !isSettled(ns.State) {
+		return LeaderHeartbeat{}, false
+	}
+	s.ownedAt = time.Now()
+
+	return LeaderHeartbeat{
+		Leader: ns.Leader,
+		Term:   ns.Term,
+		Plan:   s.appliedPlan.Version,
+	}, true
+}
+
+// RenewLease renews the lease of the current leader, returns true when the heartbeat belongs to a newer term
+// than the one settled LocalNode is in, which means LocalNode missed the election & has to join it.
+func (s *StateManager) RenewLease(hb LeaderHeartbeat) bool {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	ns := s.LocalNodeState()
+	if hb.Term > ns.Term {
+		return isSettled(ns.State)
+	}
+
+	if hb.Leader == ns.Leader && hb.Term == ns.Term {
+		s.leaseRenewedAt = time.Now()
+		delete(s.suspects, hb.Leader)
+
+		if hb.Leader == s.appliedPlan.Leader && hb.Plan == s.appliedPlan.Version {
+			s.ownedAt = s.leaseRenewedAt
+		}
+	}
+
+	return false
+}
+
+// HasNewerPlan returns true when settled LocalNode works by other plan than the one the leader advertises
+// in its heartbeat, e.g. the plan message was lost
+func (s *StateManager) HasNewerPlan(hb LeaderHeartbeat) bool {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	ns := s.LocalNodeState()
+	if hb.Leader != ns.Leader || hb.Term != ns.Term || hb.Plan == 0 || !isSettled(ns.State) {
+		return false
+	}
+
+	return hb.Leader != s.appliedPlan.Leader || hb.Plan > s.appliedPlan.Version
+}
+
+// HasNewerTerm returns true when settled LocalNode sees other nodes voting in a newer term,
+// e.g. when followers replaced the leader whose lease expired.
+func (s *StateManager) HasNewerTerm() bool {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	ns := s.LocalNodeState()
+	return isSettled(ns.State) && s.latestTerm() > ns.Term
+}
+
+// LeaseExpired returns the leader when LocalNode follows it & has not seen its heartbeat for the lease duration
+func (s *StateManager) LeaseExpired(lease time.Duration) (uint16, bool) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	ns := s.LocalNodeState()
+	if ns.Leader == 0 || ns.Leader == s.localNodeID || !isSettled(ns.State) {
+		return 0, false
+	}
+
+	return ns.Leader, time.Since(s.leaseRenewedAt) > lease
+}
+
+// Suspect excludes the node from leader candidates for the given duration, or until it renews its lease
+func (s *StateManager) Suspect(id uint16, d time.Duration) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	s.suspects[id] = time.Now().Add(d)
+	if s.leader.Leader == id {
+		s.setLeader(0, s.leader.Term)
+	}
+}
+
+func (s *StateManager) isSuspected(id uint16) bool {
+	until, ok := s.suspects[id]
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(until) {
+		delete(s.suspects, id)
+		return false
+	}
+
+	return true
+}
+
+// heartbeat renews the lease of the local leader & watches the lease of the remote one,
+// expired lease triggers new election through the FSM Elect event.
+func (c *Cluster) heartbeat() {
+	lease := time.Duration(c.Config.ElectLeaderS) * time.Second
+	interval := lease / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		c.guardOwnership()
+
+		if c.State.HasNewerTerm() {
+			c.reelect()
+			continue
+		}
+
+		if hb, ok := c.State.Heartbeat(); ok {
+			if err := c.Messenger.BroadcastHeartbeat(hb); err != nil {
+				c.logger.Error("gossip.Cluster.heartbeat()", zap.Error(err))
+			}
+
+			continue
+		}
+
+		if leader, expired := c.State.LeaseExpired(lease); expired {
+			c.logger.Warn("gossip.Cluster.heartbeat(), leader lease expired",
+				zap.Uint16("node", c.Config.NodeID),
+				zap.Uint16("leader", leader))
+
+			c.State.Suspect(leader, 2*lease)
+			c.State.StartElection()
+			c.reelect()
+		}
+	}
+}
+
+// reelect requests leader election in the latest known term, request is dropped when one is already pending
+func (c *Cluster) reelect() {
+	select {
+	case c.electCh <- struct{}{}:
+	default:
+	}
+}
+
+// isSettled returns true for states in which the cluster is not being reconfigured
+func isSettled(state StateName) bool {
+	return state == Idle || state == Starting || state == Working
+}
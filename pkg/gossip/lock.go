@@ -0,0 +1,460 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	lockAcquireMethod = "lock.acquire"
+	lockRenewMethod   = "lock.renew"
+	lockReleaseMethod = "lock.release"
+
+	// LockKeyPrefix prefixes KV keys of the lock table, the table survives change of the leader
+	LockKeyPrefix = "lock/"
+
+	lockRetryInterval = 250 * time.Millisecond
+
+	// minLockTTL leaves room for renewals through gossip
+	minLockTTL = time.Second
+
+	// lockSafetyDivisor sets the part of TTL the holder gives up its lease before the leader frees the lock,
+	// to cover clock drift & delivery of the renewal
+	lockSafetyDivisor = 10
+)
+
+var (
+	ErrLockNameEmpty  = errors.New("lock name is empty")
+	ErrLockInvalidTTL = errors.New("lock ttl is shorter than 1s")
+	ErrLockNotHeld    = errors.New("lock is not held")
+	ErrNotLeader      = errors.New("node is not the leader")
+)
+
+type (
+	// Lease is the lock granted by the leader to the local node, it is renewed in the background every third
+	// of its TTL until Unlock. When the lease was not renewed in time, it is lost & Lost channel closed
+	// shortly before the leader may grant the lock to another node, work guarded by the lock must stop then.
+	// Token grows with every grant of the lock & should be used as a fencing token against downstream systems,
+	// so that writes of a stale holder can be rejected.
+	Lease struct {
+		Name     string
+		Token    uint64
+		cluster  *Cluster
+		ttl      time.Duration
+		expiry   *time.Timer
+		lostCh   chan struct{}
+		stopCh   chan struct{}
+		lostOnce sync.Once
+		stopOnce sync.Once
+		mu       sync.Mutex
+	}
+
+	// LockRecord is the entry of the lock table kept by the leader in KV under LockKeyPrefix + Name
+	LockRecord struct {
+		Name      string        `json:"name"`
+		Holder    uint16        `json:"holder"`
+		Token     uint64        `json:"token"`
+		TTL       time.Duration `json:"ttl"`
+		ExpiresAt time.Time     `json:"expires_at"`
+	}
+
+	LockRequest struct {
+		Name  string        `json:"name"`
+		Token uint64        `json:"token,omitempty"`
+		TTL   time.Duration `json:"ttl"`
+	}
+
+	LockResponse struct {
+		Granted bool   `json:"granted"`
+		Holder  uint16 `json:"holder"`
+		Token   uint64 `json:"token"`
+	}
+)
+
+// Lock acquires the named cluster-wide lock for the local node, waiting until it is released or expires
+// on its current holder or ctx is done. Lock is granted & renewed by the leader, so it is unavailable while
+// the cluster has none & leases with TTL shorter than the reelection are lost with the leader.
+// The lock is held by the node, second Lock of the same name on the holder waits as well.
+func (c *Cluster) Lock(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	if name == "" {
+		return nil, ErrLockNameEmpty
+	}
+
+	if ttl < minLockTTL {
+		return nil, fmt.Errorf("gossip.Cluster.Lock() '%s': %w", name, ErrLockInvalidTTL)
+	}
+
+	if !c.Messenger.Ready() {
+		return nil, ErrClusterNotReady
+	}
+
+	ticker := time.NewTicker(lockRetryInterval)
+	defer ticker.Stop()
+
+	req := LockRequest{
+		Name: name,
+		TTL:  ttl,
+	}
+	for {
+		sentAt := time.Now()
+		resp, err := c.callLeader(ctx, lockAcquireMethod, req)
+		if err == nil && resp.Granted {
+			lease := &Lease{
+				Name:    name,
+				Token:   resp.Token,
+				cluster: c,
+				ttl:     ttl,
+				lostCh:  make(chan struct{}),
+				stopCh:  make(chan struct{}),
+			}
+			lease.expiry = time.AfterFunc(time.Until(lease.deadline(sentAt)), lease.lose)
+			go lease.keepAlive()
+
+			return lease, nil
+		}
+
+		if err != nil {
+			c.logger.Debug("gossip.Cluster.Lock()", zap.String("lock", name), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("gossip.Cluster.Lock() '%s': %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Locks returns the lock table as known to the local node, records may be expired
+func (c *Cluster) Locks() []LockRecord {
+	entries := c.KV.List(LockKeyPrefix)
+	records := make([]LockRecord, 0, len(entries))
+	for _, entry := range entries {
+		var record LockRecord
+		if err := json.Unmarshal(entry.Value, &record); err == nil {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+// Lost returns channel closed when the lease expired without being renewed, or was taken over
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lostCh
+}
+
+// Unlock stops renewal of the lease & releases the lock on the leader.
+// Lock that could not be released is freed by the leader once the lease expires.
+func (l *Lease) Unlock(ctx context.Context) error {
+	l.stop()
+
+	select {
+	case <-l.lostCh:
+		return fmt.Errorf("gossip.Lease.Unlock() '%s': %w", l.Name, ErrLockNotHeld)
+	default:
+	}
+
+	req := LockRequest{
+		Name:  l.Name,
+		Token: l.Token,
+	}
+	if _, err := l.cluster.callLeader(ctx, lockReleaseMethod, req); err != nil {
+		return fmt.Errorf("gossip.Lease.Unlock() '%s': %w", l.Name, err)
+	}
+
+	return nil
+}
+
+// keepAlive renews the lease every third of its TTL, failed renewals are retried until the expiry timer
+// loses the lease
+func (l *Lease) keepAlive() {
+	interval := l.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	req := LockRequest{
+		Name:  l.Name,
+		Token: l.Token,
+		TTL:   l.ttl,
+	}
+	for {
+		select {
+		case <-l.stopCh:
+			return
+		case <-l.lostCh:
+			l.cluster.logger.Warn("gossip.Lease.keepAlive(), lease expired", zap.String("lock", l.Name))
+			return
+		case <-l.cluster.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		sentAt := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		resp, err := l.cluster.callLeader(ctx, lockRenewMethod, req)
+		cancel()
+
+		if err != nil {
+			l.cluster.logger.Debug("gossip.Lease.keepAlive()", zap.String("lock", l.Name), zap.Error(err))
+			continue
+		}
+
+		if resp.Granted {
+			l.extend(l.deadline(sentAt))
+			continue
+		}
+
+		l.cluster.logger.Warn("gossip.Lease.keepAlive(), lock taken over",
+			zap.String("lock", l.Name),
+			zap.Uint16("holder", resp.Holder))
+		l.expiry.Stop()
+		l.lose()
+
+		return
+	}
+}
+
+// deadline returns local deadline of the lease renewed at sentAt, before the leader's one by the safety margin
+func (l *Lease) deadline(sentAt time.Time) time.Time {
+	return sentAt.Add(l.ttl - l.ttl/lockSafetyDivisor)
+}
+
+// extend moves the expiry timer to the deadline, unless the lease was lost or unlocked in the meantime
+func (l *Lease) extend(deadline time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.stopCh:
+		return
+	default:
+	}
+
+	if l.expiry.Stop() {
+		l.expiry.Reset(time.Until(deadline))
+	}
+}
+
+func (l *Lease) lose() {
+	l.lostOnce.Do(func() {
+		close(l.lostCh)
+	})
+}
+
+func (l *Lease) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stopOnce.Do(func() {
+		l.expiry.Stop()
+		close(l.stopCh)
+	})
+}
+
+// callLeader calls lock method of the agreed leader
+func (c *Cluster) callLeader(ctx context.Context, method string, req LockRequest) (LockResponse, error) {
+	leader, ok := c.State.Leader()
+	if !ok {
+		return LockResponse{}, ErrNoLeader
+	}
+
+	body, err := c.Messenger.Call(ctx, leader, method, req)
+	if err != nil {
+		return LockResponse{}, err
+	}
+
+	var resp LockResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return LockResponse{}, err
+	}
+
+	return resp, nil
+}
+
+// onLockAcquire grants the lock that is free, or whose lease expired. The lock table is replicated through
+// eventually consistent KV, so the new leader may miss the latest grants of the previous one,
+// it grants no lock until the longest lease it has seen could have expired.
+func (c *Cluster) onLockAcquire(_ context.Context, from uint16, body json.RawMessage) (interface{}, error) {
+	req, err := c.decodeLockRequest(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.TTL < minLockTTL {
+		return nil, ErrLockInvalidTTL
+	}
+
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+
+	now := time.Now()
+	if c.inLockFence(now, req.TTL) {
+		return LockResponse{}, nil
+	}
+
+	if record, ok := c.lockRecord(req.Name); ok && now.Before(record.ExpiresAt) {
+		return LockResponse{
+			Holder: record.Holder,
+			Token:  record.Token,
+		}, nil
+	}
+
+	record := LockRecord{
+		Name:      req.Name,
+		Holder:    from,
+		Token:     uint64(c.State.clock.Now()),
+		TTL:       req.TTL,
+		ExpiresAt: now.Add(req.TTL),
+	}
+	if err = c.putLockRecord(record); err != nil {
+		return nil, err
+	}
+
+	return LockResponse{
+		Granted: true,
+		Holder:  from,
+		Token:   record.Token,
+	}, nil
+}
+
+// onLockRenew extends the lease of the holder, unless the lock was granted to another node in the meantime.
+// While the new leader grants no locks, it adopts newer leases granted by the previous one it has not seen yet.
+func (c *Cluster) onLockRenew(_ context.Context, from uint16, body json.RawMessage) (interface{}, error) {
+	req, err := c.decodeLockRequest(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.TTL < minLockTTL {
+		return nil, ErrLockInvalidTTL
+	}
+
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+
+	now := time.Now()
+	record, ok := c.lockRecord(req.Name)
+	if (!ok || req.Token > record.Token) && c.inLockFence(now, req.TTL) {
+		record = LockRecord{
+			Name:   req.Name,
+			Holder: from,
+			Token:  req.Token,
+		}
+	} else if !ok || record.Holder != from || record.Token != req.Token {
+		return LockResponse{
+			Holder: record.Holder,
+			Token:  record.Token,
+		}, nil
+	}
+
+	record.TTL = req.TTL
+	record.ExpiresAt = now.Add(req.TTL)
+	if err = c.putLockRecord(record); err != nil {
+		return nil, err
+	}
+
+	return LockResponse{
+		Granted: true,
+		Holder:  from,
+		Token:   record.Token,
+	}, nil
+}
+
+func (c *Cluster) onLockRelease(_ context.Context, from uint16, body json.RawMessage) (interface{}, error) {
+	req, err := c.decodeLockRequest(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+
+	record, ok := c.lockRecord(req.Name)
+	if !ok || record.Holder != from || record.Token != req.Token {
+		return nil, ErrLockNotHeld
+	}
+
+	if err = c.KV.Delete(LockKeyPrefix + req.Name); err != nil {
+		return nil, err
+	}
+
+	return LockResponse{
+		Holder: from,
+		Token:  record.Token,
+	}, nil
+}
+
+// inLockFence returns true until the longest lease seen by LocalNode, or the requested one, could have expired
+// since it took office
+func (c *Cluster) inLockFence(now time.Time, ttl time.Duration) bool {
+	_, since := c.State.LeaderSince()
+	if maxTTL := time.Duration(atomic.LoadInt64(&c.lockMaxTTL)); maxTTL > ttl {
+		ttl = maxTTL
+	}
+
+	return now.Before(since.Add(ttl))
+}
+
+// onLockRecord tracks the longest lease of the lock table, every node watches it to fence grants once it leads
+func (c *Cluster) onLockRecord(entry KVEntry) {
+	var record LockRecord
+	if entry.Deleted || json.Unmarshal(entry.Value, &record) != nil {
+		return
+	}
+
+	for {
+		maxTTL := atomic.LoadInt64(&c.lockMaxTTL)
+		if int64(record.TTL) <= maxTTL || atomic.CompareAndSwapInt64(&c.lockMaxTTL, maxTTL, int64(record.TTL)) {
+			return
+		}
+	}
+}
+
+// decodeLockRequest decodes request received by the leader, the lock table is managed only by the leader
+func (c *Cluster) decodeLockRequest(body json.RawMessage) (LockRequest, error) {
+	if leader, _ := c.State.Leader(); leader != c.Config.NodeID {
+		return LockRequest{}, ErrNotLeader
+	}
+
+	var req LockRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return LockRequest{}, err
+	}
+
+	if req.Name == "" {
+		return LockRequest{}, ErrLockNameEmpty
+	}
+
+	return req, nil
+}
+
+func (c *Cluster) lockRecord(name string) (LockRecord, bool) {
+	entry, ok := c.KV.Get(LockKeyPrefix + name)
+	if !ok {
+		return LockRecord{}, false
+	}
+
+	var record LockRecord
+	if err := json.Unmarshal(entry.Value, &record); err != nil {
+		c.logger.Warn("gossip.Cluster.lockRecord()", zap.String("lock", name), zap.Error(err))
+		return LockRecord{}, false
+	}
+
+	return record, true
+}
+
+func (c *Cluster) putLockRecord(record LockRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return c.KV.Put(LockKeyPrefix+record.Name, value)
+}
@@ -0,0 +1,138 @@
+package gossip
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// LWWMap is last-writer-wins map, every key is register whose entry with higher Version wins,
+	// NodeID of the writer breaks ties. Deleted keys are kept as entries with Deleted set for the TTL.
+	LWWMap struct {
+		name        string
+		store       *CRDTStore
+		localNodeID uint16
+		entries     map[string]KVEntry
+		hash        stateHash
+		mu          sync.RWMutex
+	}
+)
+
+func newLWWMap(name string, store *CRDTStore) *LWWMap {
+	return &LWWMap{
+		name:        name,
+		store:       store,
+		localNodeID: store.messenger.localNodeID,
+		entries:     make(map[string]KVEntry),
+	}
+}
+
+// Set sets value of the key
+func (m *LWWMap) Set(key string, value []byte) error {
+	return m.write(KVEntry{Key: key, Value: value})
+}
+
+// Delete deletes the key
+func (m *LWWMap) Delete(key string) error {
+	return m.write(KVEntry{Key: key, Deleted: true})
+}
+
+// Get returns value of the key, false when the key is not set or was deleted
+func (m *LWWMap) Get(key string) ([]byte, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[key]
+	if !ok || entry.Deleted {
+		return nil, false
+	}
+
+	return entry.Value, true
+}
+
+// Keys returns sorted keys of the map
+func (m *LWWMap) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, 0, len(m.entries))
+	for key, entry := range m.entries {
+		if !entry.Deleted {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// write stores the local entry, its Version is taken under the lock, so that it is newer than any merged entry
+func (m *LWWMap) write(entry KVEntry) error {
+	m.mu.Lock()
+	entry.Version = m.store.clock.Now()
+	entry.NodeID = m.localNodeID
+	m.entries[entry.Key] = entry
+	m.hash.reset()
+	m.mu.Unlock()
+
+	return m.store.broadcast(m.name, lwwMapType, entry.Key, []KVEntry{entry})
+}
+
+func (m *LWWMap) typ() string {
+	return lwwMapType
+}
+
+func (m *LWWMap) state() interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := make([]KVEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func (m *LWWMap) merge(data json.RawMessage) error {
+	var entries []KVEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range entries {
+		m.store.clock.Update(entry.Version)
+
+		if current, ok := m.entries[entry.Key]; !ok || entry.newerThan(current) {
+			m.entries[entry.Key] = entry
+			m.hash.reset()
+		}
+	}
+
+	return nil
+}
+
+func (m *LWWMap) digest() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.hash.of(m.entries)
+}
+
+// prune drops keys deleted longer than ttl ago
+func (m *LWWMap) prune(ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, entry := range m.entries {
+		if entry.Deleted && entry.Version.expired(ttl) {
+			delete(m.entries, key)
+			m.hash.reset()
+		}
+	}
+}
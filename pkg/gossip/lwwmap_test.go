@@ -0,0 +1,76 @@
+package gossip
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLWWMap(t *testing.T) {
+	a, _ := testStore(1).LWWMap("m")
+	b, _ := testStore(2).LWWMap("m")
+
+	_ = a.Set("x", []byte("a"))
+	_ = b.Set("y", []byte("b"))
+	mergeAll(t, a, b)
+
+	// later write wins, later delete as well
+	_ = b.Set("x", []byte("b"))
+	_ = a.Delete("y")
+	mergeAll(t, b, a)
+	mergeAll(t, a, b)
+	assertConverged(t, a, b)
+
+	if value, ok := a.Get("x"); !ok || string(value) != "b" {
+		t.Fatalf("Get(x) = %q, %v, want b", value, ok)
+	}
+
+	if _, ok := b.Get("y"); ok {
+		t.Fatal("deleted key is in the map")
+	}
+
+	if got := a.Keys(); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Fatalf("Keys() = %v, want [x]", got)
+	}
+}
+
+func TestLWWMap_Tie(t *testing.T) {
+	a, _ := testStore(1).LWWMap("m")
+	b, _ := testStore(2).LWWMap("m")
+
+	version := a.store.clock.Now()
+	a.entries["x"] = KVEntry{Key: "x", Value: []byte("a"), Version: version, NodeID: 1}
+	b.entries["x"] = KVEntry{Key: "x", Value: []byte("b"), Version: version, NodeID: 2}
+
+	mergeAll(t, a, b)
+	assertConverged(t, a, b)
+
+	if value, _ := a.Get("x"); string(value) != "b" {
+		t.Fatalf("Get(x) = %q, want the write of the bigger NodeID", value)
+	}
+}
+
+func TestLWWMap_prune(t *testing.T) {
+	m, _ := testStore(1).LWWMap("m")
+	old := newHLC(time.Now().Add(-2 * time.Minute))
+	recent := newHLC(time.Now())
+
+	m.entries = map[string]KVEntry{
+		"deleted":        {Key: "deleted", Deleted: true, Version: old},
+		"recent-deleted": {Key: "recent-deleted", Deleted: true, Version: recent},
+		"set":            {Key: "set", Value: []byte("v"), Version: old},
+	}
+	m.prune(time.Minute)
+
+	want := []string{"recent-deleted", "set"}
+	got := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		got = append(got, key)
+	}
+	sort.Strings(got)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("entries after prune() = %v, want %v", got, want)
+	}
+}
@@ -1,10 +1,65 @@
 package gossip
 
+import "encoding/json"
+
+// EnvelopeVersion is the version of the Envelope format sent by this node,
+// envelopes of newer versions are dropped.
+const EnvelopeVersion = 1
+
+const (
+	workerRegistryMessage = "worker_registry"
+	assignmentPlanMessage = "assignment_plan"
+	workerReleaseMessage  = "worker_release"
+	heartbeatMessage      = "leader_heartbeat"
+
+	leadershipTransferMessage        = "transfer_leadership"
+	leadershipTransferRequestMessage = "transfer_leadership_request"
+
+	rpcRequestMessage  = "rpc_request"
+	rpcResponseMessage = "rpc_response"
+
+	publishMessage      = "publish"
+	broadcastAckMessage = "broadcast_ack"
+
+	queryMessage         = "query"
+	queryResponseMessage = "query_response"
+
+	stateDeltaMessage = "state_delta"
+
+	kvMessage        = "kv"
+	crdtDeltaMessage = "crdt_delta"
+)
+
 type (
-	SelectLeaderMessage struct {
-		Method string `json:"method"`
-		Args   struct {
-			Leader uint16 `json:"leader"`
-		} `json:"args"`
+	// Envelope wraps every message sent between nodes, Payload is routed to the MessageHandler
+	// registered for its Type with Messenger.Handle. Sender is zero for messages sent from outside the cluster.
+	Envelope struct {
+		Type    string          `json:"type"`
+		Version int             `json:"version"`
+		Sender  uint16          `json:"sender"`
+		Payload json.RawMessage `json:"payload"`
 	}
+
+	// MessageHandler handles Envelope of registered type, returned error is logged.
+	// Handlers are called one at a time & must not block, long-running work should be moved to a goroutine.
+	MessageHandler func(msg Envelope) error
 )
+
+// Decode unmarshals Payload into v
+func (e Envelope) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+func newEnvelope(typ string, sender uint16, payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{
+		Type:    typ,
+		Version: EnvelopeVersion,
+		Sender:  sender,
+		Payload: data,
+	})
+}
@@ -2,46 +2,302 @@ package gossip
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/hashicorp/memberlist"
 	"go.uber.org/zap"
+	"sync"
+)
+
+var (
+	ErrNodeNotMember         = errors.New("node is not a member of the cluster")
+	ErrMessageTypeEmpty      = errors.New("message type is empty")
+	ErrMessageTypeRegistered = errors.New("message handler is already registered")
+	ErrMessageQueueFull      = errors.New("message queue is full")
 )
 
 type (
+	// Messenger sends messages to other nodes & routes received ones to handlers registered per Envelope.Type.
 	Messenger struct {
-		logger *zap.Logger
-		ml     *memberlist.Memberlist
-		tlq    *memberlist.TransmitLimitedQueue
+		callID        uint64 // first fields, 64-bit aligned for atomic access
+		publishSeq    uint64
+		logger        *zap.Logger
+		localNodeID   uint16
+		ml            *memberlist.Memberlist
+		tlq           *memberlist.TransmitLimitedQueue
+		msgCh         chan<- []byte
+		handlers      map[string]MessageHandler
+		rpcHandlers   map[string]RPCHandler
+		pending       map[uint64]pendingCall
+		acks          map[uint64]chan uint16
+		waiting       map[string]*Broadcast
+		subID         uint64
+		subscriptions []subscription
+		topicModes    []topicMode
+		latestSeq     map[uint16]map[string]uint64
+		appendSeen    map[uint16]map[uint64]struct{}
+		rwm           sync.RWMutex
 	}
 )
 
-func newMessenger(logger *zap.Logger, ml *memberlist.Memberlist, tlq *memberlist.TransmitLimitedQueue) *Messenger {
-	return &Messenger{
-		logger: logger,
-		ml:     ml,
-		tlq:    tlq,
+func newMessenger(logger *zap.Logger,
+	localNodeID uint16,
+	tlq *memberlist.TransmitLimitedQueue,
+	msgCh chan<- []byte,
+) *Messenger {
+	m := &Messenger{
+		logger:      logger,
+		localNodeID: localNodeID,
+		tlq:         tlq,
+		msgCh:       msgCh,
+		handlers:    make(map[string]MessageHandler),
+		rpcHandlers: make(map[string]RPCHandler),
+		pending:     make(map[uint64]pendingCall),
+		publishSeq:  initialPublishSeq(),
+		acks:        make(map[uint64]chan uint16),
+		waiting:     make(map[string]*Broadcast),
+		latestSeq:   make(map[uint16]map[string]uint64),
+		appendSeen:  make(map[uint16]map[uint64]struct{}),
+	}
+	m.handlers[rpcRequestMessage] = m.onRPCRequest
+	m.handlers[rpcResponseMessage] = m.onRPCResponse
+	m.handlers[publishMessage] = m.onPublication
+	m.handlers[broadcastAckMessage] = m.onBroadcastAck
+
+	return m
+}
+
+// Handle registers MessageHandler for the message type, it can be done before the cluster is ready.
+func (m *Messenger) Handle(typ string, handler MessageHandler) error {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if typ == "" {
+		return ErrMessageTypeEmpty
+	}
+
+	if _, ok := m.handlers[typ]; ok {
+		return fmt.Errorf("gossip.Messenger.Handle() '%s': %w", typ, ErrMessageTypeRegistered)
+	}
+
+	m.handlers[typ] = handler
+
+	return nil
+}
+
+// Ready returns true once the local node is a member of the cluster & messages can be sent
+func (m *Messenger) Ready() bool {
+	return m.memberlist() != nil
+}
+
+// BroadcastMessage gossips payload wrapped in Envelope of given type to all nodes,
+// newer message of the same type invalidates the queued one. Gossiped message may be delivered more than once.
+func (m *Messenger) BroadcastMessage(typ string, payload interface{}) error {
+	data, err := newEnvelope(typ, m.localNodeID, payload)
+	if err != nil {
+		return fmt.Errorf("gossip.Messenger.BroadcastMessage() '%s': %w", typ, err)
 	}
+
+	m.Broadcast(typ, data)
+
+	return nil
+}
+
+// SendMessage sends payload wrapped in Envelope of given type to the node over reliable (TCP) connection
+func (m *Messenger) SendMessage(id uint16, typ string, payload interface{}) error {
+	data, err := newEnvelope(typ, m.localNodeID, payload)
+	if err != nil {
+		return fmt.Errorf("gossip.Messenger.SendMessage() '%s': %w", typ, err)
+	}
+
+	return m.SendToNode(id, data)
+}
+
+// SendMessageToAll sends payload wrapped in Envelope of given type to every other member of the cluster
+// over reliable (TCP) connection.
+func (m *Messenger) SendMessageToAll(typ string, payload interface{}) error {
+	data, err := newEnvelope(typ, m.localNodeID, payload)
+	if err != nil {
+		return fmt.Errorf("gossip.Messenger.SendMessageToAll() '%s': %w", typ, err)
+	}
+
+	m.SendToAll(data)
+
+	return nil
 }
 
 func (m *Messenger) Broadcast(topic string, data []byte) {
-	bc := NewBroadcast(m.logger, m.ml, topic, data, nil)
-	m.tlq.QueueBroadcast(bc)
+	m.queue(topic, data, nil)
 }
 
-func (m *Messenger) SelectLeader(leaderID uint16) error {
-	var data []byte
-	var err error
-	topic := "select_leader"
+// SendToAll sends data to every other member of the cluster over reliable (TCP) connection.
+// Members that could not be reached are logged & skipped.
+func (m *Messenger) SendToAll(data []byte) {
+	ml := m.memberlist()
+	if ml == nil {
+		m.logger.Warn("gossip.Messenger.SendToAll()", zap.Error(ErrClusterNotReady))
+		return
+	}
+
+	local := ml.LocalNode().Name
+	for _, node := range ml.Members() {
+		if node.Name == local {
+			continue
+		}
 
-	mes := SelectLeaderMessage{
-		Method: topic,
+		if err := ml.SendReliable(node, data); err != nil {
+			m.logger.Warn("gossip.Messenger.SendToAll()", zap.String("node.Name", node.Name), zap.Error(err))
+		}
 	}
-	mes.Args.Leader = leaderID
+}
 
-	if data, err = json.Marshal(mes); err != nil {
-		return err
+// SendToNode sends data to the node with given Config.NodeID over reliable (TCP) connection
+func (m *Messenger) SendToNode(id uint16, data []byte) error {
+	ml := m.memberlist()
+	if ml == nil {
+		return fmt.Errorf("gossip.Messenger.SendToNode() node %d: %w", id, ErrClusterNotReady)
 	}
 
-	m.Broadcast(topic, data)
+	node, ok := m.member(ml, id)
+	if !ok {
+		return fmt.Errorf("gossip.Messenger.SendToNode() node %d: %w", id, ErrNodeNotMember)
+	}
+
+	if err := ml.SendReliable(node, data); err != nil {
+		return fmt.Errorf("gossip.Messenger.SendToNode() node %d: %w", id, err)
+	}
 
 	return nil
 }
+
+// BroadcastWorkerRegistry propagates WorkerRegistry to all nodes
+func (m *Messenger) BroadcastWorkerRegistry(registry WorkerRegistry) error {
+	return m.BroadcastMessage(workerRegistryMessage, registry)
+}
+
+// SendAssignmentPlan distributes AssignmentPlan computed by the leader to all nodes
+func (m *Messenger) SendAssignmentPlan(plan AssignmentPlan) error {
+	return m.SendMessageToAll(assignmentPlanMessage, plan)
+}
+
+// SendWorkerRelease acknowledges to all nodes that workers were released by the local node
+func (m *Messenger) SendWorkerRelease(release WorkerRelease) error {
+	return m.SendMessageToAll(workerReleaseMessage, release)
+}
+
+// BroadcastHeartbeat gossips LeaderHeartbeat, newer heartbeat invalidates the queued one
+func (m *Messenger) BroadcastHeartbeat(hb LeaderHeartbeat) error {
+	return m.BroadcastMessage(heartbeatMessage, hb)
+}
+
+// dispatch routes received message to the handler registered for its type
+func (m *Messenger) dispatch(data []byte) {
+	var msg Envelope
+	if err := json.Unmarshal(data, &msg); err != nil {
+		m.logger.Warn("gossip.Messenger.dispatch(), invalid envelope dropped", zap.Error(err))
+		return
+	}
+
+	if msg.Version > EnvelopeVersion {
+		m.logger.Warn("gossip.Messenger.dispatch(), unsupported envelope version dropped",
+			zap.String("type", msg.Type),
+			zap.Int("version", msg.Version),
+			zap.Uint16("sender", msg.Sender))
+		return
+	}
+
+	m.rwm.RLock()
+	handler, ok := m.handlers[msg.Type]
+	m.rwm.RUnlock()
+
+	if !ok {
+		m.logger.Debug("gossip.Messenger.dispatch(), no handler registered",
+			zap.String("type", msg.Type),
+			zap.Uint16("sender", msg.Sender))
+		return
+	}
+
+	if err := handler(msg); err != nil {
+		m.logger.Error("gossip.Messenger.dispatch()",
+			zap.String("type", msg.Type),
+			zap.Uint16("sender", msg.Sender),
+			zap.Error(err))
+	}
+}
+
+// queue queues broadcast, notifyCh is closed once it was transmitted or invalidated, the returned Broadcast tells which.
+// Queued broadcast of the same name is invalidated by memberlist without calling Invalidates, so it is marked here.
+func (m *Messenger) queue(name string, data []byte, notifyCh chan<- struct{}) *Broadcast {
+	bc := NewBroadcast(m.logger, m.memberlist(), name, data, notifyCh)
+
+	m.rwm.Lock()
+	if old, ok := m.waiting[name]; ok {
+		old.invalidate()
+		delete(m.waiting, name)
+	}
+	if notifyCh != nil {
+		m.waiting[name] = bc
+	}
+	m.rwm.Unlock()
+
+	m.tlq.QueueBroadcast(bc)
+
+	return bc
+}
+
+// forget stops tracking broadcast awaited by the caller, it is no longer invalidated by newer ones
+func (m *Messenger) forget(bc *Broadcast) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if m.waiting[bc.Name()] == bc {
+		delete(m.waiting, bc.Name())
+	}
+}
+
+// attach sets Memberlist the messages are sent with, once the local node is created
+func (m *Messenger) attach(ml *memberlist.Memberlist) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	m.ml = ml
+}
+
+func (m *Messenger) memberlist() *memberlist.Memberlist {
+	m.rwm.RLock()
+	defer m.rwm.RUnlock()
+
+	return m.ml
+}
+
+// member finds memberlist.Node by NodeID advertised in its NodeMeta
+func (m *Messenger) member(ml *memberlist.Memberlist, id uint16) (*memberlist.Node, bool) {
+	for _, node := range ml.Members() {
+		if nodeID, ok := memberNodeID(node); ok && nodeID == id {
+			return node, true
+		}
+	}
+
+	return nil, false
+}
+
+// remoteNodeIDs returns NodeIDs of all members except the local node
+func (m *Messenger) remoteNodeIDs(ml *memberlist.Memberlist) []uint16 {
+	var ids []uint16
+	for _, node := range ml.Members() {
+		if nodeID, ok := memberNodeID(node); ok && nodeID != m.localNodeID {
+			ids = append(ids, nodeID)
+		}
+	}
+
+	return ids
+}
+
+func memberNodeID(node *memberlist.Node) (uint16, bool) {
+	var nodeMeta NodeMeta
+	if err := json.Unmarshal(node.Meta, &nodeMeta); err != nil {
+		return 0, false
+	}
+
+	return nodeMeta.NodeID, true
+}
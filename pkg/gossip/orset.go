@@ -0,0 +1,260 @@
+package gossip
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+type (
+	// ORSet is observed-remove set of strings. Every add is tagged by a dot, replica & its sequence number,
+	// & the causal context records dots seen by the replica. Merge keeps dots present on both sides & dots
+	// the other side has not seen yet, so remove drops only the observed adds & concurrent add wins.
+	// Removed elements leave no tombstones, a replica partitioned for any time can not bring them back,
+	// as its dots are covered by the context of the replicas that removed them.
+	ORSet struct {
+		name  string
+		store *CRDTStore
+		set   orSetState
+		hash  stateHash
+		mu    sync.RWMutex
+	}
+
+	// orSetState holds dots of the elements, sequence numbers by replica, & the causal context
+	orSetState struct {
+		Elements map[string]map[string]uint64 `json:"elements"`
+		Context  causalContext                `json:"context"`
+	}
+
+	// causalContext holds contiguous sequence numbers seen by replica in Clock & the ones seen out of order
+	// in Cloud, they are compacted into Clock once the gap is filled
+	causalContext struct {
+		Clock map[string]uint64          `json:"clock"`
+		Cloud map[string]map[uint64]bool `json:"cloud,omitempty"`
+	}
+)
+
+func newORSet(name string, store *CRDTStore) *ORSet {
+	return &ORSet{
+		name:  name,
+		store: store,
+		set:   newORSetState(),
+	}
+}
+
+// Add adds the element to the set
+func (s *ORSet) Add(element string) error {
+	s.mu.Lock()
+	delta := s.set.add(s.store.replica, element)
+	s.hash.reset()
+	s.mu.Unlock()
+
+	return s.store.broadcast(s.name, orSetType, element, delta)
+}
+
+// Remove removes the element from the set, it is a no-op when the element is not in the set
+func (s *ORSet) Remove(element string) error {
+	s.mu.Lock()
+	delta, ok := s.set.remove(element)
+	s.hash.reset()
+	s.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return s.store.broadcast(s.name, orSetType, element, delta)
+}
+
+// Contains returns true when the element is in the set
+func (s *ORSet) Contains(element string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.set.Elements[element]) > 0
+}
+
+// Elements returns sorted elements of the set
+func (s *ORSet) Elements() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.set.elements()
+}
+
+func (s *ORSet) typ() string {
+	return orSetType
+}
+
+func (s *ORSet) state() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := newORSetState()
+	set.merge(s.set)
+
+	return set
+}
+
+func (s *ORSet) merge(data json.RawMessage) error {
+	var set orSetState
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set.merge(set)
+	s.hash.reset()
+
+	return nil
+}
+
+func (s *ORSet) digest() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.hash.of(s.set)
+}
+
+// add replaces dots of the element with the new dot of the replica, returns delta with the new dot
+// & the replaced ones in its context
+func (o orSetState) add(replica, element string) orSetState {
+	seq := o.Context.Clock[replica] + 1
+	o.Context.Clock[replica] = seq
+
+	delta := newORSetState()
+	for r, s := range o.Elements[element] {
+		delta.Context.addDot(r, s)
+	}
+	delta.Context.addDot(replica, seq)
+	delta.Elements[element] = map[string]uint64{replica: seq}
+
+	o.Elements[element] = map[string]uint64{replica: seq}
+
+	return delta
+}
+
+// remove drops dots of the element, returns delta with them in its context, false when there were none
+func (o orSetState) remove(element string) (orSetState, bool) {
+	dots, ok := o.Elements[element]
+	if !ok {
+		return orSetState{}, false
+	}
+
+	delta := newORSetState()
+	for r, s := range dots {
+		delta.Context.addDot(r, s)
+	}
+	delete(o.Elements, element)
+
+	return delta, true
+}
+
+// merge keeps dots present on both sides & dots not seen by the other side, then joins the contexts
+func (o orSetState) merge(other orSetState) {
+	for element, dots := range o.Elements {
+		for r, s := range dots {
+			if other.Elements[element][r] != s && other.Context.contains(r, s) {
+				delete(dots, r)
+			}
+		}
+	}
+
+	for element, dots := range other.Elements {
+		for r, s := range dots {
+			if o.Context.contains(r, s) || s < o.Elements[element][r] {
+				continue
+			}
+
+			if _, ok := o.Elements[element]; !ok {
+				o.Elements[element] = make(map[string]uint64)
+			}
+			o.Elements[element][r] = s
+		}
+	}
+
+	for element, dots := range o.Elements {
+		if len(dots) == 0 {
+			delete(o.Elements, element)
+		}
+	}
+
+	o.Context.merge(other.Context)
+}
+
+func (o orSetState) elements() []string {
+	elements := make([]string, 0, len(o.Elements))
+	for element := range o.Elements {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+
+	return elements
+}
+
+// contains returns true when the dot was seen
+func (c causalContext) contains(replica string, seq uint64) bool {
+	return seq <= c.Clock[replica] || c.Cloud[replica][seq]
+}
+
+// addDot records the dot & compacts the cloud of its replica
+func (c causalContext) addDot(replica string, seq uint64) {
+	if c.contains(replica, seq) {
+		return
+	}
+
+	if _, ok := c.Cloud[replica]; !ok {
+		c.Cloud[replica] = make(map[uint64]bool)
+	}
+	c.Cloud[replica][seq] = true
+	c.compact(replica)
+}
+
+func (c causalContext) merge(other causalContext) {
+	for r, seq := range other.Clock {
+		if seq > c.Clock[r] {
+			c.Clock[r] = seq
+		}
+		c.compact(r)
+	}
+
+	for r, seqs := range other.Cloud {
+		for seq := range seqs {
+			c.addDot(r, seq)
+		}
+	}
+}
+
+// compact moves contiguous dots of the replica from the cloud into the clock
+func (c causalContext) compact(replica string) {
+	cloud, ok := c.Cloud[replica]
+	if !ok {
+		return
+	}
+
+	for seq := range cloud {
+		if seq <= c.Clock[replica] {
+			delete(cloud, seq)
+		}
+	}
+	for cloud[c.Clock[replica]+1] {
+		delete(cloud, c.Clock[replica]+1)
+		c.Clock[replica]++
+	}
+
+	if len(cloud) == 0 {
+		delete(c.Cloud, replica)
+	}
+}
+
+func newORSetState() orSetState {
+	return orSetState{
+		Elements: make(map[string]map[string]uint64),
+		Context: causalContext{
+			Clock: make(map[string]uint64),
+			Cloud: make(map[string]map[uint64]bool),
+		},
+	}
+}
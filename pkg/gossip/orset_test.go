@@ -0,0 +1,195 @@
+package gossip
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// applyDelta merges delta of the local update into the set, as onDelta does
+func applyDelta(t *testing.T, s *ORSet, delta orSetState) {
+	t.Helper()
+
+	data, err := json.Marshal(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.merge(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestORSet(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, a, b *ORSet)
+		want []string
+	}{
+		{
+			name: "add",
+			run: func(t *testing.T, a, b *ORSet) {
+				_ = a.Add("x")
+				_ = b.Add("y")
+			},
+			want: []string{"x", "y"},
+		},
+		{
+			name: "observed remove",
+			run: func(t *testing.T, a, b *ORSet) {
+				_ = a.Add("x")
+				syncCRDT(t, a, b)
+				_ = b.Remove("x")
+			},
+			want: []string{},
+		},
+		{
+			name: "concurrent add wins",
+			run: func(t *testing.T, a, b *ORSet) {
+				_ = a.Add("x")
+				syncCRDT(t, a, b)
+				_ = a.Remove("x")
+				_ = b.Add("x")
+			},
+			want: []string{"x"},
+		},
+		{
+			name: "unobserved add survives remove",
+			run: func(t *testing.T, a, b *ORSet) {
+				_ = a.Add("x")
+				_ = b.Add("x")
+				_ = a.Remove("x")
+			},
+			want: []string{"x"},
+		},
+		{
+			name: "re-add after remove",
+			run: func(t *testing.T, a, b *ORSet) {
+				_ = a.Add("x")
+				_ = a.Remove("x")
+				_ = a.Add("x")
+			},
+			want: []string{"x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := testStore(1).ORSet("s")
+			b, _ := testStore(2).ORSet("s")
+			tt.run(t, a, b)
+
+			mergeAll(t, a, b)
+			mergeAll(t, b, a)
+			assertConverged(t, a, b)
+
+			if got := a.Elements(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Elements() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestORSet_Partitioned(t *testing.T) {
+	a, _ := testStore(1).ORSet("s")
+	b, _ := testStore(2).ORSet("s")
+	c, _ := testStore(3).ORSet("s")
+
+	_ = a.Add("x")
+	mergeAll(t, a, b, c)
+
+	// c is partitioned with the add, while a & b remove the element
+	_ = b.Remove("x")
+	mergeAll(t, a, b)
+	if a.Contains("x") {
+		t.Fatal("removed element is in the set")
+	}
+
+	// removed element leaves no data behind
+	if len(a.set.Elements) != 0 {
+		t.Fatalf("elements of removed element kept: %v", a.set.Elements)
+	}
+
+	// partition heals, the add known to c is not brought back
+	mergeAll(t, c, a, b)
+	assertConverged(t, a, b, c)
+	if c.Contains("x") {
+		t.Fatal("element removed during the partition is back in the set")
+	}
+}
+
+func TestORSet_Deltas(t *testing.T) {
+	a, _ := testStore(1).ORSet("s")
+	b, _ := testStore(2).ORSet("s")
+
+	replica := a.store.replica
+	d1 := a.set.add(replica, "x")
+	d2 := a.set.add(replica, "y")
+	d3, _ := a.set.remove("x")
+
+	// deltas arrive out of order & more than once, the first one is lost
+	applyDelta(t, b, d2)
+	if !b.set.Context.Cloud[replica][2] {
+		t.Fatalf("dot seen out of order is not in the cloud: %+v", b.set.Context)
+	}
+	applyDelta(t, b, d3)
+	applyDelta(t, b, d3)
+	if got := b.Elements(); !reflect.DeepEqual(got, []string{"y"}) {
+		t.Fatalf("Elements() = %v, want [y]", got)
+	}
+
+	// the lost delta arrives late & does not bring back the removed element
+	applyDelta(t, b, d1)
+	if b.Contains("x") {
+		t.Fatal("late delta brought back the removed element")
+	}
+
+	if len(b.set.Context.Cloud) != 0 || b.set.Context.Clock[replica] != 2 {
+		t.Fatalf("causal context is not compacted: %+v", b.set.Context)
+	}
+
+	assertConverged(t, a, b)
+}
+
+func TestOrSetState_merge(t *testing.T) {
+	a, _ := testStore(1).ORSet("s")
+	b, _ := testStore(2).ORSet("s")
+	c, _ := testStore(3).ORSet("s")
+
+	_ = a.Add("x")
+	_ = b.Add("x")
+	_ = b.Add("y")
+	syncCRDT(t, b, c)
+	_ = c.Remove("y")
+	_ = c.Add("z")
+
+	states := []orSetState{a.set, b.set, c.set}
+	tests := []struct {
+		name  string
+		order []int
+	}{
+		{name: "in order", order: []int{0, 1, 2}},
+		{name: "reversed", order: []int{2, 1, 0}},
+		{name: "duplicated", order: []int{1, 0, 1, 2, 2, 0}},
+	}
+
+	var want orSetState
+	for key, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newORSetState()
+			for _, i := range tt.order {
+				got.merge(states[i])
+			}
+
+			if !reflect.DeepEqual(got.elements(), []string{"x", "z"}) {
+				t.Fatalf("elements() = %v, want [x z]", got.elements())
+			}
+
+			if key == 0 {
+				want = got
+			} else if !reflect.DeepEqual(got, want) {
+				t.Fatalf("merge() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
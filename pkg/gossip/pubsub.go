@@ -0,0 +1,248 @@
+package gossip
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// TopicLatest delivers only the latest value of the topic, queued older publications are invalidated
+	TopicLatest TopicMode = iota
+	// TopicAppend delivers every publication of the topic
+	TopicAppend
+)
+
+// seenWindow is the number of append-only publications remembered per publisher to drop duplicates
+const seenWindow = 1024
+
+type (
+	TopicMode int
+
+	// TopicHandler receives data published to the subscribed topic by node identified by from.
+	// Handlers are called one at a time & must not block.
+	TopicHandler func(topic string, from uint16, data []byte)
+
+	Publication struct {
+		Topic  string `json:"topic"`
+		Seq    uint64 `json:"seq"`
+		Append bool   `json:"append,omitempty"`
+		Ack    uint64 `json:"ack,omitempty"`
+		Data   []byte `json:"data"`
+	}
+
+	subscription struct {
+		id      uint64
+		pattern string
+		handler TopicHandler
+	}
+
+	topicMode struct {
+		pattern string
+		mode    TopicMode
+	}
+)
+
+// SetTopicMode sets delivery mode of published topics matching the pattern, TopicLatest is the default.
+// Patterns are matched with path.Match, e.g. "progress/*", the last matching pattern wins.
+func (m *Messenger) SetTopicMode(pattern string, mode TopicMode) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("gossip.Messenger.SetTopicMode() '%s': %w", pattern, err)
+	}
+
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	m.topicModes = append(m.topicModes, topicMode{
+		pattern: pattern,
+		mode:    mode,
+	})
+
+	return nil
+}
+
+// Subscribe registers handler of topics matching the pattern, patterns are matched with path.Match,
+// e.g. "progress/*" matches "progress/pl_db". Returned function cancels the subscription.
+func (m *Messenger) Subscribe(pattern string, handler TopicHandler) (func(), error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("gossip.Messenger.Subscribe() '%s': %w", pattern, err)
+	}
+
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	m.subID++
+	id := m.subID
+	m.subscriptions = append(m.subscriptions, subscription{
+		id:      id,
+		pattern: pattern,
+		handler: handler,
+	})
+
+	return func() {
+		m.unsubscribe(id)
+	}, nil
+}
+
+// Publish gossips data to subscribers of the topic on all nodes, including the local one.
+// Depending on TopicMode, queued publication of the topic is invalidated by the newer one,
+// or every publication is delivered.
+func (m *Messenger) Publish(topic string, data []byte) error {
+	if _, err := m.publish(topic, data, 0, nil); err != nil {
+		return fmt.Errorf("gossip.Messenger.Publish() '%s': %w", topic, err)
+	}
+
+	return nil
+}
+
+// publish queues the publication, it is acknowledged by receivers with non-zero ack id
+// & notifyCh is closed once it was transmitted or invalidated. Local subscribers receive it through
+// the message queue, so that handlers are called one at a time together with remote publications.
+func (m *Messenger) publish(topic string, data []byte, ack uint64, notifyCh chan<- struct{}) (*Broadcast, error) {
+	pub := Publication{
+		Topic:  topic,
+		Seq:    atomic.AddUint64(&m.publishSeq, 1),
+		Append: ack != 0 || m.topicMode(topic) == TopicAppend,
+		Ack:    ack,
+		Data:   data,
+	}
+
+	msg, err := newEnvelope(publishMessage, m.localNodeID, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	name := publishMessage + ":" + topic
+	if pub.Append {
+		name += "#" + strconv.FormatUint(pub.Seq, 10)
+	}
+	select {
+	case m.msgCh <- msg:
+	default:
+		return nil, ErrMessageQueueFull
+	}
+
+	return m.queue(name, msg, notifyCh), nil
+}
+
+func (m *Messenger) onPublication(msg Envelope) error {
+	var pub Publication
+	if err := msg.Decode(&pub); err != nil {
+		return err
+	}
+
+	// every received copy is acknowledged, the ack of the first one may have been lost
+	if pub.Ack != 0 && msg.Sender != m.localNodeID {
+		go m.acknowledge(msg.Sender, pub.Ack)
+	}
+
+	if !m.isNewPublication(msg.Sender, pub) {
+		return nil
+	}
+
+	m.deliver(msg.Sender, pub)
+
+	return nil
+}
+
+func (m *Messenger) deliver(from uint16, pub Publication) {
+	m.rwm.RLock()
+	var handlers []TopicHandler
+	for _, sub := range m.subscriptions {
+		if ok, _ := path.Match(sub.pattern, pub.Topic); ok {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	m.rwm.RUnlock()
+
+	for _, handler := range handlers {
+		handler(pub.Topic, from, pub.Data)
+	}
+}
+
+// isNewPublication drops duplicates of gossiped publications, as well as publications of the latest-value
+// topic that are older than the one already delivered
+func (m *Messenger) isNewPublication(from uint16, pub Publication) bool {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if !pub.Append {
+		latest, ok := m.latestSeq[from]
+		if !ok {
+			latest = make(map[string]uint64)
+			m.latestSeq[from] = latest
+		}
+
+		if pub.Seq <= latest[pub.Topic] {
+			return false
+		}
+		latest[pub.Topic] = pub.Seq
+
+		return true
+	}
+
+	seen, ok := m.appendSeen[from]
+	if !ok {
+		seen = make(map[uint64]struct{})
+		m.appendSeen[from] = seen
+	}
+
+	if _, ok = seen[pub.Seq]; ok {
+		return false
+	}
+	seen[pub.Seq] = struct{}{}
+
+	if len(seen) > seenWindow {
+		for seq := range seen {
+			if seq+seenWindow < pub.Seq {
+				delete(seen, seq)
+			}
+		}
+	}
+
+	return true
+}
+
+// forgetPublisher drops sequences of publications of the node that left, a restarted node publishes
+// with sequences starting from the current time, so its publications are not dropped anyway
+func (m *Messenger) forgetPublisher(nodeID uint16) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	delete(m.latestSeq, nodeID)
+	delete(m.appendSeen, nodeID)
+}
+
+func (m *Messenger) topicMode(topic string) TopicMode {
+	m.rwm.RLock()
+	defer m.rwm.RUnlock()
+
+	mode := TopicLatest
+	for _, tm := range m.topicModes {
+		if ok, _ := path.Match(tm.pattern, topic); ok {
+			mode = tm.mode
+		}
+	}
+
+	return mode
+}
+
+func (m *Messenger) unsubscribe(id uint64) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	for key, sub := range m.subscriptions {
+		if sub.id == id {
+			m.subscriptions = append(m.subscriptions[:key], m.subscriptions[key+1:]...)
+			return
+		}
+	}
+}
+
+// initialPublishSeq starts publication sequence from the current time, so that publications
+// of a restarted node are not mistaken for the already delivered ones
+func initialPublishSeq() uint64 {
+	return uint64(time.Now().UnixNano())
+}
@@ -0,0 +1,45 @@
+package gossip
+
+import (
+	"go.uber.org/zap"
+	"testing"
+)
+
+func TestMessenger_isNewPublication(t *testing.T) {
+	m := newMessenger(zap.NewNop(), 1, nil, nil)
+
+	tests := []struct {
+		name string
+		from uint16
+		pub  Publication
+		want bool
+	}{
+		{name: "latest", from: 2, pub: Publication{Topic: "t", Seq: 10}, want: true},
+		{name: "latest duplicate", from: 2, pub: Publication{Topic: "t", Seq: 10}, want: false},
+		{name: "latest older", from: 2, pub: Publication{Topic: "t", Seq: 9}, want: false},
+		{name: "latest other topic", from: 2, pub: Publication{Topic: "u", Seq: 9}, want: true},
+		{name: "latest other node", from: 3, pub: Publication{Topic: "t", Seq: 9}, want: true},
+		{name: "append", from: 2, pub: Publication{Topic: "a", Seq: 5, Append: true}, want: true},
+		{name: "append older", from: 2, pub: Publication{Topic: "a", Seq: 4, Append: true}, want: true},
+		{name: "append duplicate", from: 2, pub: Publication{Topic: "a", Seq: 5, Append: true}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.isNewPublication(tt.from, tt.pub); got != tt.want {
+				t.Fatalf("isNewPublication() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	m.forgetPublisher(2)
+	if _, ok := m.latestSeq[2]; ok {
+		t.Fatal("forgetPublisher() kept latest sequences of the node")
+	}
+	if _, ok := m.appendSeen[2]; ok {
+		t.Fatal("forgetPublisher() kept append sequences of the node")
+	}
+	if _, ok := m.latestSeq[3]; !ok {
+		t.Fatal("forgetPublisher() dropped sequences of other node")
+	}
+}
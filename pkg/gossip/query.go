@@ -0,0 +1,328 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkersQuery is answered by every node with WorkersQueryResult of the workers running on it
+const WorkersQuery = "workers"
+
+var (
+	ErrQueryNameEmpty      = errors.New("query name is empty")
+	ErrQueryNameRegistered = errors.New("query handler is already registered")
+)
+
+type (
+	// QueryHandler answers query of the node identified by from, returned value is marshalled to JSON.
+	// Context is canceled when the query times out.
+	QueryHandler func(ctx context.Context, from uint16, payload json.RawMessage) (interface{}, error)
+
+	// QueryFilter selects nodes answering the query, empty filter selects all nodes.
+	QueryFilter struct {
+		// Nodes answer only when listed
+		Nodes []uint16 `json:"nodes,omitempty"`
+		// Workers are answered only by nodes running at least one of them
+		Workers []string `json:"workers,omitempty"`
+	}
+
+	Query struct {
+		ID      uint64          `json:"id"`
+		Name    string          `json:"name"`
+		Filter  QueryFilter     `json:"filter"`
+		Timeout time.Duration   `json:"timeout"`
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	// QueryResponse is the answer of a single node, Error is set when its QueryHandler failed.
+	QueryResponse struct {
+		ID    uint64          `json:"id"`
+		Node  uint16          `json:"node"`
+		Body  json.RawMessage `json:"body,omitempty"`
+		Error string          `json:"error,omitempty"`
+	}
+
+	WorkersQueryResult struct {
+		Workers []string          `json:"workers"`
+		Epochs  map[string]uint64 `json:"epochs"`
+	}
+
+	queries struct {
+		id       uint64
+		handlers map[string]QueryHandler
+		pending  map[uint64]*pendingQuery
+		answered map[string]time.Time
+		rwm      sync.RWMutex
+	}
+
+	pendingQuery struct {
+		respCh    chan QueryResponse
+		responded map[uint16]bool
+	}
+)
+
+func newQueries() *queries {
+	return &queries{
+		id:       initialQueryID(),
+		handlers: make(map[string]QueryHandler),
+		pending:  make(map[uint64]*pendingQuery),
+		answered: make(map[string]time.Time),
+	}
+}
+
+// initialQueryID starts query IDs from the current time, so that queries of a restarted node
+// are not mistaken for the already answered ones
+func initialQueryID() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+// HandleQuery registers QueryHandler answering queries of the name on the local node
+func (c *Cluster) HandleQuery(name string, handler QueryHandler) error {
+	q := c.queries
+	q.rwm.Lock()
+	defer q.rwm.Unlock()
+
+	if name == "" {
+		return ErrQueryNameEmpty
+	}
+
+	if _, ok := q.handlers[name]; ok {
+		return fmt.Errorf("gossip.Cluster.HandleQuery() '%s': %w", name, ErrQueryNameRegistered)
+	}
+
+	q.handlers[name] = handler
+
+	return nil
+}
+
+// Query gossips the query to all nodes, nodes selected by the filter answer with a direct message.
+// Responses are streamed to the returned channel, at most one per node, until ctx is done & the channel is closed.
+// Context without deadline times out after 5s.
+func (c *Cluster) Query(ctx context.Context, name string, payload interface{}, filter QueryFilter) (<-chan QueryResponse, error) {
+	ml := c.Messenger.memberlist()
+	if ml == nil {
+		return nil, ErrClusterNotReady
+	}
+
+	var cancel context.CancelFunc = func() {}
+	if _, ok := ctx.Deadline(); !ok {
+		ctx, cancel = context.WithTimeout(ctx, defaultCallTimeout)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("gossip.Cluster.Query() '%s': %w", name, err)
+	}
+
+	deadline, _ := ctx.Deadline()
+	query := Query{
+		ID:      atomic.AddUint64(&c.queries.id, 1),
+		Name:    name,
+		Filter:  filter,
+		Timeout: time.Until(deadline),
+		Payload: body,
+	}
+
+	msg, err := newEnvelope(queryMessage, c.Config.NodeID, query)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("gossip.Cluster.Query() '%s': %w", name, err)
+	}
+
+	pq := &pendingQuery{
+		respCh:    make(chan QueryResponse, ml.NumMembers()),
+		responded: make(map[uint16]bool),
+	}
+	c.queries.rwm.Lock()
+	c.queries.pending[query.ID] = pq
+	c.queries.rwm.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		cancel()
+
+		c.queries.rwm.Lock()
+		delete(c.queries.pending, query.ID)
+		close(pq.respCh)
+		c.queries.rwm.Unlock()
+	}()
+
+	c.Messenger.queue(queryMessage+":"+strconv.FormatUint(query.ID, 10), msg, nil)
+	go c.answerQuery(c.Config.NodeID, query)
+
+	return pq.respCh, nil
+}
+
+// onQuery answers the query in a goroutine, duplicates of the gossiped query are answered only once
+func (c *Cluster) onQuery(msg Envelope) error {
+	var query Query
+	if err := msg.Decode(&query); err != nil {
+		return err
+	}
+
+	if msg.Sender == 0 || !c.queries.markAnswered(msg.Sender, query) {
+		return nil
+	}
+
+	go c.answerQuery(msg.Sender, query)
+
+	return nil
+}
+
+func (c *Cluster) onQueryResponse(msg Envelope) error {
+	var response QueryResponse
+	if err := msg.Decode(&response); err != nil {
+		return err
+	}
+
+	response.Node = msg.Sender
+	c.queries.deliver(response)
+
+	return nil
+}
+
+func (c *Cluster) answerQuery(from uint16, query Query) {
+	if !c.matchesQuery(query.Filter) {
+		return
+	}
+
+	c.queries.rwm.RLock()
+	handler, ok := c.queries.handlers[query.Name]
+	c.queries.rwm.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	timeout := query.Timeout
+	if timeout <= 0 {
+		timeout = defaultCallTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	response := QueryResponse{
+		ID:   query.ID,
+		Node: c.Config.NodeID,
+	}
+
+	resp, err := handler(ctx, from, query.Payload)
+	if err == nil {
+		response.Body, err = json.Marshal(resp)
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	if from == c.Config.NodeID {
+		c.queries.deliver(response)
+		return
+	}
+
+	if err = c.Messenger.SendMessage(from, queryResponseMessage, response); err != nil {
+		c.logger.Warn("gossip.Cluster.answerQuery()",
+			zap.String("query", query.Name),
+			zap.Uint16("from", from),
+			zap.Error(err))
+	}
+}
+
+func (c *Cluster) matchesQuery(filter QueryFilter) bool {
+	if len(filter.Nodes) > 0 {
+		var listed bool
+		for _, id := range filter.Nodes {
+			if id == c.Config.NodeID {
+				listed = true
+				break
+			}
+		}
+
+		if !listed {
+			return false
+		}
+	}
+
+	if len(filter.Workers) > 0 {
+		running := make(map[string]bool)
+		for _, name := range c.Workers.Running() {
+			running[name] = true
+		}
+
+		for _, name := range filter.Workers {
+			if running[name] {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// queryWorkers answers WorkersQuery
+func (c *Cluster) queryWorkers(context.Context, uint16, json.RawMessage) (interface{}, error) {
+	running := c.Workers.Running()
+	epochs := c.State.LocalEpochs()
+
+	result := WorkersQueryResult{
+		Workers: running,
+		Epochs:  make(map[string]uint64, len(running)),
+	}
+	for _, name := range running {
+		result.Epochs[name] = epochs[name]
+	}
+
+	return result, nil
+}
+
+// deliver streams the response of the pending query, one per node
+func (q *queries) deliver(response QueryResponse) {
+	q.rwm.Lock()
+	defer q.rwm.Unlock()
+
+	pq, ok := q.pending[response.ID]
+	if !ok || pq.responded[response.Node] {
+		return
+	}
+	pq.responded[response.Node] = true
+
+	select {
+	case pq.respCh <- response:
+	default:
+	}
+}
+
+// markAnswered returns false when the query was already answered, expired entries are pruned
+func (q *queries) markAnswered(from uint16, query Query) bool {
+	q.rwm.Lock()
+	defer q.rwm.Unlock()
+
+	now := time.Now()
+	for key, expires := range q.answered {
+		if now.After(expires) {
+			delete(q.answered, key)
+		}
+	}
+
+	key := strconv.Itoa(int(from)) + ":" + strconv.FormatUint(query.ID, 10)
+	if _, ok := q.answered[key]; ok {
+		return false
+	}
+
+	timeout := query.Timeout
+	if timeout <= 0 {
+		timeout = defaultCallTimeout
+	}
+	q.answered[key] = now.Add(timeout)
+
+	return true
+}
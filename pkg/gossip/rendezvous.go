@@ -0,0 +1,44 @@
+package gossip
+
+import (
+	"math"
+	"strconv"
+)
+
+type (
+	// RendezvousStrategy assigns every worker to the node with the highest weighted score
+	// (highest random weight hashing). Nodes with bigger NodeMeta.Weight take proportionally more workers,
+	// membership change moves only workers of the node that left or the ones won by the node that joined.
+	RendezvousStrategy struct{}
+)
+
+func (s *RendezvousStrategy) Assign(nodes []AssignmentNode, workers []string) map[uint16][]string {
+	assignment := newAssignment(nodes)
+	if len(nodes) == 0 {
+		return assignment
+	}
+
+	for _, worker := range workers {
+		var owner uint16
+		best := math.Inf(-1)
+		for _, node := range nodes {
+			score := rendezvousScore(node, worker)
+			if score > best || (score == best && node.ID < owner) {
+				best = score
+				owner = node.ID
+			}
+		}
+
+		assignment[owner] = append(assignment[owner], worker)
+	}
+
+	return assignment
+}
+
+// rendezvousScore computes weighted score -weight / ln(h), where h is the hash of node & worker mapped to (0, 1)
+func rendezvousScore(node AssignmentNode, worker string) float64 {
+	h := hashKey(strconv.Itoa(int(node.ID)) + "#" + worker)
+	unit := (float64(h>>11) + 0.5) / float64(uint64(1)<<53)
+
+	return -node.Weight / math.Log(unit)
+}
@@ -0,0 +1,87 @@
+package gossip
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRendezvousStrategy_Assign(t *testing.T) {
+	s := &RendezvousStrategy{}
+	workers := testWorkers(1000)
+	nodes := testNodes(1, 2, 3)
+
+	assignment := s.Assign(nodes, workers)
+	if len(owners(t, assignment)) != len(workers) {
+		t.Fatalf("%d workers assigned, want %d", len(owners(t, assignment)), len(workers))
+	}
+
+	if !reflect.DeepEqual(assignment, s.Assign(nodes, workers)) {
+		t.Fatal("assignment is not deterministic")
+	}
+
+	if assignment = s.Assign(nil, workers); len(assignment) != 0 {
+		t.Fatalf("assignment without nodes has %d nodes", len(assignment))
+	}
+}
+
+func TestRendezvousStrategy_Weights(t *testing.T) {
+	s := &RendezvousStrategy{}
+	workers := testWorkers(6000)
+
+	tests := []struct {
+		name    string
+		weights []float64
+	}{
+		{name: "equal", weights: []float64{1, 1, 1}},
+		{name: "proportional", weights: []float64{1, 2, 3}},
+		{name: "fractional", weights: []float64{0.5, 1.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sum float64
+			for _, weight := range tt.weights {
+				sum += weight
+			}
+
+			assignment := s.Assign(testNodes(tt.weights...), workers)
+			for key, weight := range tt.weights {
+				want := float64(len(workers)) * weight / sum
+				got := float64(len(assignment[uint16(key+1)]))
+				if math.Abs(got-want) > want*0.1 {
+					t.Fatalf("node %d with weight %.1f got %.0f workers, want ~%.0f", key+1, weight, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRendezvousStrategy_Membership(t *testing.T) {
+	s := &RendezvousStrategy{}
+	workers := testWorkers(3000)
+	three := s.Assign(testNodes(1, 1, 1), workers)
+	four := s.Assign(testNodes(1, 1, 1, 1), workers)
+
+	t.Run("join", func(t *testing.T) {
+		m := moved(t, three, four)
+		for worker, id := range m {
+			if id != 4 {
+				t.Fatalf("worker %s moved to node %d, only the joined node 4 may take workers", worker, id)
+			}
+		}
+
+		if share := float64(len(m)) / float64(len(workers)); share < 0.2 || share > 0.3 {
+			t.Fatalf("%.2f of workers moved, want ~1/4", share)
+		}
+	})
+
+	t.Run("leave", func(t *testing.T) {
+		from := owners(t, four)
+		for worker := range moved(t, four, three) {
+			if from[worker] != 4 {
+				t.Fatalf("worker %s of node %d moved, only workers of the left node 4 may move", worker, from[worker])
+			}
+		}
+	})
+}
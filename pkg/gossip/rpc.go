@@ -0,0 +1,214 @@
+package gossip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCallTimeout = 5 * time.Second
+
+var (
+	ErrRPCMethodEmpty      = errors.New("rpc method is empty")
+	ErrRPCMethodRegistered = errors.New("rpc handler is already registered")
+	ErrRPCMethodNotFound   = errors.New("rpc method not found")
+	ErrRPCRemote           = errors.New("remote call failed")
+)
+
+type (
+	// RPCHandler answers request of the node identified by from, returned value is marshalled to JSON.
+	// Context is canceled when the caller's timeout expires.
+	RPCHandler func(ctx context.Context, from uint16, req json.RawMessage) (interface{}, error)
+
+	RPCRequest struct {
+		ID      uint64          `json:"id"`
+		Method  string          `json:"method"`
+		Timeout time.Duration   `json:"timeout"`
+		Body    json.RawMessage `json:"body"`
+	}
+
+	RPCResponse struct {
+		ID    uint64          `json:"id"`
+		Body  json.RawMessage `json:"body,omitempty"`
+		Error string          `json:"error,omitempty"`
+	}
+
+	// pendingCall awaits the response of the callee
+	pendingCall struct {
+		callee uint16
+		respCh chan RPCResponse
+	}
+)
+
+// HandleRPC registers RPCHandler answering Call of the method
+func (m *Messenger) HandleRPC(method string, handler RPCHandler) error {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if method == "" {
+		return ErrRPCMethodEmpty
+	}
+
+	if _, ok := m.rpcHandlers[method]; ok {
+		return fmt.Errorf("gossip.Messenger.HandleRPC() '%s': %w", method, ErrRPCMethodRegistered)
+	}
+
+	m.rpcHandlers[method] = handler
+
+	return nil
+}
+
+// Call sends request to the node over reliable (TCP) connection & waits for its response until ctx is done,
+// context without deadline times out after 5s. Call of the local node is answered directly.
+func (m *Messenger) Call(ctx context.Context, id uint16, method string, req interface{}) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultCallTimeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("gossip.Messenger.Call() '%s': %w", method, err)
+	}
+
+	if id == m.localNodeID {
+		resp, err := m.answer(ctx, id, method, body)
+		if err != nil {
+			return nil, fmt.Errorf("gossip.Messenger.Call() node %d '%s': %w", id, method, err)
+		}
+
+		return resp, nil
+	}
+
+	deadline, _ := ctx.Deadline()
+	request := RPCRequest{
+		ID:      atomic.AddUint64(&m.callID, 1),
+		Method:  method,
+		Timeout: time.Until(deadline),
+		Body:    body,
+	}
+
+	respCh := make(chan RPCResponse, 1)
+	m.rwm.Lock()
+	m.pending[request.ID] = pendingCall{
+		callee: id,
+		respCh: respCh,
+	}
+	m.rwm.Unlock()
+
+	defer func() {
+		m.rwm.Lock()
+		delete(m.pending, request.ID)
+		m.rwm.Unlock()
+	}()
+
+	if err = m.SendMessage(id, rpcRequestMessage, request); err != nil {
+		return nil, fmt.Errorf("gossip.Messenger.Call() '%s': %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gossip.Messenger.Call() node %d '%s': %w", id, method, ctx.Err())
+	case resp := <-respCh:
+		if resp.Error != "" {
+			return nil, fmt.Errorf("gossip.Messenger.Call() node %d '%s': %w: %s", id, method, ErrRPCRemote, resp.Error)
+		}
+
+		return resp.Body, nil
+	}
+}
+
+// onRPCRequest answers the request in a goroutine, so that slow handlers do not block message delivery
+func (m *Messenger) onRPCRequest(msg Envelope) error {
+	var request RPCRequest
+	if err := msg.Decode(&request); err != nil {
+		return err
+	}
+
+	if msg.Sender == 0 {
+		return fmt.Errorf("'%s': %w", request.Method, ErrNodeNotMember)
+	}
+
+	go func() {
+		timeout := request.Timeout
+		if timeout <= 0 {
+			timeout = defaultCallTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		response := RPCResponse{
+			ID: request.ID,
+		}
+
+		body, err := m.answer(ctx, msg.Sender, request.Method, request.Body)
+		if err != nil {
+			response.Error = err.Error()
+		} else {
+			response.Body = body
+		}
+
+		if err = m.SendMessage(msg.Sender, rpcResponseMessage, response); err != nil {
+			m.logger.Warn("gossip.Messenger.onRPCRequest()",
+				zap.String("method", request.Method),
+				zap.Uint16("caller", msg.Sender),
+				zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// onRPCResponse delivers the response to the pending Call, responses of timed out calls are dropped,
+// as well as responses of other nodes than the callee
+func (m *Messenger) onRPCResponse(msg Envelope) error {
+	var response RPCResponse
+	if err := msg.Decode(&response); err != nil {
+		return err
+	}
+
+	m.rwm.RLock()
+	call, ok := m.pending[response.ID]
+	m.rwm.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	if msg.Sender != call.callee {
+		m.logger.Warn("gossip.Messenger.onRPCResponse(), response of another node than the callee dropped",
+			zap.Uint64("id", response.ID),
+			zap.Uint16("callee", call.callee),
+			zap.Uint16("sender", msg.Sender))
+		return nil
+	}
+
+	select {
+	case call.respCh <- response:
+	default:
+	}
+
+	return nil
+}
+
+func (m *Messenger) answer(ctx context.Context, from uint16, method string, req json.RawMessage) (json.RawMessage, error) {
+	m.rwm.RLock()
+	handler, ok := m.rpcHandlers[method]
+	m.rwm.RUnlock()
+
+	if !ok {
+		return nil, ErrRPCMethodNotFound
+	}
+
+	resp, err := handler(ctx, from, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(resp)
+}
@@ -17,31 +17,22 @@ const (
 	Starting    StateName = "starting"
 	Stopping    StateName = "stopping"
 
-	Join      EventName = "join"
-	Joined    EventName = "joined"
-	Assemble  EventName = "assemble"
-	Assembled EventName = "assembled"
-	Elect     EventName = "elect"
-	Elected   EventName = "elected"
-	Assign    EventName = "assign"
-	Assigned  EventName = "assigned"
-	Start     EventName = "start"
-	Started   EventName = "started"
-	Stop      EventName = "stop"
-	Stopped   EventName = "stopped"
-	Finish    EventName = "finish"
-
-	PlDb Worker = "pl_db"
-	UaDb Worker = "ua_db"
-	RoDb Worker = "ro_db"
-	KzDb Worker = "kz_db"
-	PtDb Worker = "pt_db"
-	BgDb Worker = "bg_db"
-	UzDb Worker = "uz_db"
+	Join        EventName = "join"
+	Joined      EventName = "joined"
+	Assemble    EventName = "assemble"
+	Assembled   EventName = "assembled"
+	Elect       EventName = "elect"
+	Elected     EventName = "elected"
+	Assign      EventName = "assign"
+	Assigned    EventName = "assigned"
+	Start       EventName = "start"
+	Started     EventName = "started"
+	Stop        EventName = "stop"
+	Stopped     EventName = "stopped"
+	Reconfigure EventName = "reconfigure"
+	Finish      EventName = "finish"
 )
 
-var Workers = []Worker{PlDb, UaDb, RoDb, KzDb, PtDb, BgDb, UzDb}
-
 type (
 	State struct {
 		Indexes []uint16
@@ -49,37 +40,51 @@ type (
 		Working map[string]bool
 	}
 
+	// NodeState is owned by the node it describes, Generation grows with every restart of the node,
+	// Clock is its HLC of the last change used for merging, Timestamp is kept only for display.
 	NodeState struct {
-		Name      string    `json:"name"`
-		State     StateName `json:"state"`
-		Leader    uint16    `json:"leader"`
-		Workers   []Worker  `json:"workers"`
-		Working   bool      `json:"working"`
-		Timestamp time.Time `json:"timestamp"`
+		Name       string              `json:"name"`
+		State      StateName           `json:"state"`
+		Leader     uint16              `json:"leader"`
+		Term       uint64              `json:"term"`
+		Workers    []string            `json:"workers"`
+		Epochs     map[string]uint64   `json:"epochs"`
+		Working    bool                `json:"working"`
+		Generation uint64              `json:"generation"`
+		Transfer   *LeadershipTransfer `json:"transfer,omitempty"`
+		Clock      HLC                 `json:"clock"`
+		Timestamp  time.Time           `json:"timestamp"`
+	}
+
+	// AssignmentPlan is computed by the leader & distributed to all nodes, Version grows with every plan.
+	// Previous holds owners of the workers before the plan, new owner waits for their release.
+	// Epochs holds ownership epoch of each worker, it is bumped every time the worker changes its owner.
+	AssignmentPlan struct {
+		Version     uint64              `json:"version"`
+		Leader      uint16              `json:"leader"`
+		Assignments map[uint16][]string `json:"assignments"`
+		Previous    map[string]uint16   `json:"previous"`
+		Epochs      map[string]uint64   `json:"epochs"`
 	}
 
 	StateName = string
 	EventName = string
-	Worker    = string
 )
 
 func newState(localNodeID uint16, localNodeName string, localNodeState StateName) *State {
-	working := make(map[string]bool)
-	for _, worker := range Workers {
-		working[worker] = false
-	}
-
 	return &State{
 		Indexes: []uint16{localNodeID},
 		Nodes: map[uint16]NodeState{
 			localNodeID: {
-				Name:      localNodeName,
-				State:     localNodeState,
-				Workers:   make([]string, 0),
-				Timestamp: time.Now().UTC(),
+				Name:       localNodeName,
+				State:      localNodeState,
+				Workers:    make([]string, 0),
+				Epochs:     make(map[string]uint64),
+				Generation: newGeneration(),
+				Timestamp:  time.Now().UTC(),
 			},
 		},
-		Working: working,
+		Working: make(map[string]bool),
 	}
 }
 
@@ -97,6 +102,7 @@ func newFSM(sm *StateManager) *fsm.FSM {
 		{Name: Started, Src: []string{Starting}, Dst: Working},
 		{Name: Stop, Src: []string{Idle, Configuring, Joining, Assembling, Electing, Assigning, Working, Starting, Stopping}, Dst: Stopping},
 		{Name: Stopped, Src: []string{Stopping}, Dst: Configuring},
+		{Name: Reconfigure, Src: []string{Idle, Joining, Assembling, Electing, Assigning, Working, Starting, Stopping}, Dst: Configuring},
 		{Name: Finish, Src: []string{Assembling}, Dst: Idle},
 	}
 
@@ -115,3 +121,12 @@ func newFSM(sm *StateManager) *fsm.FSM {
 
 	return fsm.NewFSM(Starting, events, callbacks)
 }
+
+// newerThan returns true when n should replace o: newer Generation wins, otherwise higher Clock
+func (n NodeState) newerThan(o NodeState) bool {
+	if n.Generation != o.Generation {
+		return n.Generation > o.Generation
+	}
+
+	return n.Clock > o.Clock
+}
@@ -11,22 +11,52 @@ import (
 
 type (
 	StateManager struct {
-		debug         bool
-		logger        *zap.Logger
-		fsm           *fsm.FSM
-		localNodeID   uint16
-		localNodeName string
-		state         *State
-		rwm           sync.RWMutex
+		debug          bool
+		logger         *zap.Logger
+		fsm            *fsm.FSM
+		localNodeID    uint16
+		localNodeName  string
+		strategy       AssignmentStrategy
+		quorum         int
+		meta           map[uint16]NodeMeta
+		suspects       map[uint16]time.Time
+		leader         LeaderChange
+		leaderSince    time.Time
+		leaderSubs     []chan LeaderChange
+		leaseRenewedAt time.Time
+		ownedAt        time.Time
+		plans          map[uint16]AssignmentPlan
+		clock          *Clock
+		tombstones     map[uint16]Tombstone
+		tombstoneTTL   time.Duration
+		appliedPlan    AssignmentPlan
+		state          *State
+		rwm            sync.RWMutex
 	}
 )
 
-func newStateManager(debug bool, logger *zap.Logger, localNodeID uint16, localNodeName string, active bool) *StateManager {
+func newStateManager(debug bool,
+	logger *zap.Logger,
+	localNodeID uint16,
+	localNodeName string,
+	strategy AssignmentStrategy,
+	quorum int,
+	tombstoneTTL time.Duration,
+	maxClockSkew time.Duration,
+) *StateManager {
 	sm := &StateManager{
 		debug:         debug,
 		logger:        logger,
 		localNodeID:   localNodeID,
 		localNodeName: localNodeName,
+		strategy:      strategy,
+		quorum:        quorum,
+		meta:          make(map[uint16]NodeMeta),
+		suspects:      make(map[uint16]time.Time),
+		plans:         make(map[uint16]AssignmentPlan),
+		clock:         newClock(logger, maxClockSkew),
+		tombstones:    make(map[uint16]Tombstone),
+		tombstoneTTL:  tombstoneTTL,
 	}
 
 	sm.fsm = newFSM(sm)
@@ -55,33 +85,56 @@ func (s *StateManager) HasNode(key uint16) bool {
 	return ok
 }
 
+// RemoveNode removes the node that left the cluster & buries it with Tombstone
 func (s *StateManager) RemoveNode(id uint16) bool {
 	s.rwm.Lock()
 	defer s.rwm.Unlock()
 
-	if _, ok := s.state.Nodes[id]; !ok {
+	node, ok := s.state.Nodes[id]
+	if !ok {
 		return false
 	}
 
-	delete(s.state.Nodes, id)
-
+	s.bury(id, node)
+	s.removeNode(id)
 	s.setIndexes()
 
 	return true
 }
 
-func (s *StateManager) LocalState() map[uint16]NodeState {
+func (s *StateManager) removeNode(id uint16) {
+	delete(s.state.Nodes, id)
+	delete(s.meta, id)
+	if s.leader.Leader == id {
+		s.setLeader(0, s.leader.Term)
+	}
+}
+
+// SetNodeMeta stores NodeMeta advertised by the node
+func (s *StateManager) SetNodeMeta(meta NodeMeta) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	s.meta[meta.NodeID] = meta
+}
+
+// FullState returns copy of all known node states
+func (s *StateManager) FullState() map[uint16]NodeState {
 	s.rwm.RLock()
 	defer s.rwm.RUnlock()
 
-	ns := s.LocalNodeState()
-	mns := map[uint16]NodeState{
-		s.localNodeID: ns,
+	nodes := make(map[uint16]NodeState, len(s.state.Nodes))
+	for key, node := range s.state.Nodes {
+		nodes[key] = node
 	}
 
-	return mns
+	return nodes
 }
 
+// ImportState merges node states received from a peer, entry of newer Generation or with higher Clock wins.
+// Entries of buried node generations are ignored, so that departed nodes are not re-added.
+// Remote copy of the LocalNode entry newer than the local one, e.g. remembered from before restart,
+// moves the local Clock past it, so that the current LocalNode state wins on the next sync.
 func (s *StateManager) ImportState(state map[uint16]NodeState) {
 	s.rwm.Lock()
 	defer s.rwm.Unlock()
@@ -89,17 +142,31 @@ func (s *StateManager) ImportState(state map[uint16]NodeState) {
 	var hasNew bool
 
 	for key, node := range state {
+		s.clock.Update(node.Clock)
+
 		if key == s.localNodeID {
+			if ns := s.LocalNodeState(); node.newerThan(ns) {
+				if node.Generation > ns.Generation {
+					ns.Generation = node.Generation + 1
+				}
+				s.setLocalNodeState(ns)
+			}
 			continue
 		}
 
-		if _, ok := s.state.Nodes[key]; !ok {
+		if s.isBuried(key, node) {
+			continue
+		}
+
+		current, ok := s.state.Nodes[key]
+		if !ok {
 			hasNew = true
 			s.state.Nodes[key] = node
 			continue
 		}
 
-		if node.Timestamp.After(s.state.Nodes[key].Timestamp) {
+		if node.newerThan(current) {
+			s.warnStaleEpochs(key, node)
 			s.state.Nodes[key] = node
 			continue
 		}
@@ -110,6 +177,25 @@ func (s *StateManager) ImportState(state map[uint16]NodeState) {
 	}
 }
 
+// warnStaleEpochs logs workers the working node runs with epoch older than the one known to LocalNode
+func (s *StateManager) warnStaleEpochs(id uint16, node NodeState) {
+	if node.State != Working {
+		return
+	}
+
+	known := s.knownEpochs()
+	for worker, epoch := range node.Epochs {
+		if epoch < known[worker] {
+			s.logger.Warn("gossip.StateManager.ImportState(), stale worker owner",
+				zap.String("localNode.Name", s.localNodeName),
+				zap.Uint16("node", id),
+				zap.String("worker", worker),
+				zap.Uint64("epoch", epoch),
+				zap.Uint64("current", known[worker]))
+		}
+	}
+}
+
 func (s *StateManager) CurrentState() string {
 	s.rwm.RLock()
 	defer s.rwm.RUnlock()
@@ -144,69 +230,185 @@ func (s *StateManager) IsLeader() bool {
 	return s.LocalNodeState().Leader == s.localNodeID
 }
 
-// ElectLeader sets leader for LocalNode & returns true when 100% quorum is achieved
-func (s *StateManager) ElectLeader() bool {
+// StartElection joins the election round already started by other nodes, i.e. the latest term newer than
+// the one of the leader agreed by LocalNode, or starts new term when there is none. Votes from previous terms
+// are ignored.
+func (s *StateManager) StartElection() uint64 {
 	s.rwm.Lock()
 	defer s.rwm.Unlock()
 
-	// choose node with the smallest Config.NodeID
-	i := 0
-	min := uint16(math.MaxUint16)
-	for id := range s.state.Nodes {
-		if id < min {
-			min = id
-			i++
-		}
+	term := s.latestTerm()
+	if term <= s.leader.Term {
+		term++
 	}
 
-	if i == 0 {
-		return true
-	}
+	return s.setTerm(term)
+}
+
+// RestartElection starts new term after the election in the latest one timed out
+func (s *StateManager) RestartElection() uint64 {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
 
-	// set the node as the leader, if not already
-	if s.LocalNodeState().Leader != min {
-		ns := s.state.Nodes[s.localNodeID]
-		ns.Leader = min
-		ns.Timestamp = time.Now().UTC()
-		s.state.Nodes[s.localNodeID] = ns
+	return s.setTerm(s.latestTerm() + 1)
+}
+
+func (s *StateManager) setTerm(term uint64) uint64 {
+	ns := s.LocalNodeState()
+	if ns.Term != term {
+		ns.Term = term
+		s.setLocalNodeState(ns)
 	}
 
+	return term
+}
+
+// ElectLeader votes for the leader in the latest known term & returns true when quorum of nodes agrees on it.
+// Candidate already backed by quorum of the term is followed, otherwise the node votes for the preferred one.
+func (s *StateManager) ElectLeader() bool {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	term := s.latestTerm()
+	quorum := s.quorumSize()
+
+	votes := make(map[uint16]int)
 	for _, node := range s.state.Nodes {
-		if node.Leader != min {
+		if node.Term == term && node.Leader != 0 {
+			votes[node.Leader]++
+		}
+	}
+
+	candidate := uint16(math.MaxUint16)
+	for id, count := range votes {
+		if count >= quorum && id < candidate {
+			candidate = id
+		}
+	}
+
+	if candidate == math.MaxUint16 {
+		var ok bool
+		if candidate, ok = s.preferredCandidate(term); !ok {
 			return false
 		}
 	}
 
+	// join the latest term & vote for the candidate, if not already
+	if ns := s.LocalNodeState(); ns.Leader != candidate || ns.Term != term {
+		ns.Leader = candidate
+		ns.Term = term
+		s.setLocalNodeState(ns)
+		votes[candidate]++
+	}
+
+	if votes[candidate] < quorum {
+		return false
+	}
+
+	s.leaseRenewedAt = time.Now()
+	s.setLeader(candidate, term)
+
 	return true
 }
 
-func (s *StateManager) AssignWorkers() {
+// ComputePlan computes AssignmentPlan of workers sorted by name using AssignmentStrategy & applies it to LocalNode.
+// Only the leader computes the plan, followers receive it with ImportPlan.
+func (s *StateManager) ComputePlan(workers []WorkerConfig) AssignmentPlan {
 	s.rwm.Lock()
 	defer s.rwm.Unlock()
 
-	var workers []Worker
-	length := len(s.state.Nodes)
-	index := s.getLocalNodeIndex()
-	for key, worker := range Workers {
-		if key%length == index {
-			workers = append(workers, worker)
-		}
+	assignment := assignTagged(s.strategy, s.assignmentNodes(), workers, s.previousAssignment())
+	if assigned := countAssigned(assignment); assigned < len(workers) {
+		s.logger.Warn("gossip.StateManager.ComputePlan(), workers without node having their tags left out",
+			zap.Int("workers", len(workers)),
+			zap.Int("assigned", assigned))
 	}
 
-	ns := s.LocalNodeState()
-	ns.Workers = workers
-	ns.Timestamp = time.Now().UTC()
-	s.state.Nodes[s.localNodeID] = ns
+	previous := s.previousOwners()
+	plan := AssignmentPlan{
+		Version:     s.latestPlanVersion() + 1,
+		Leader:      s.localNodeID,
+		Assignments: assignment,
+		Previous:    previous,
+		Epochs:      s.nextEpochs(assignment, previous),
+	}
+	s.plans[s.localNodeID] = plan
+
+	return plan
 }
 
-func (s *StateManager) ReleaseWorkers() {
+// ImportPlan stores AssignmentPlan received from the leader, returns false for outdated plan
+func (s *StateManager) ImportPlan(plan AssignmentPlan) bool {
 	s.rwm.Lock()
 	defer s.rwm.Unlock()
 
+	if current, ok := s.plans[plan.Leader]; ok && current.Version >= plan.Version {
+		return false
+	}
+
+	s.plans[plan.Leader] = plan
+
+	return true
+}
+
+// ApplyPlan assigns LocalNode its workers from the newest AssignmentPlan of the current leader,
+// returns false while there is no such plan that has not been applied yet.
+func (s *StateManager) ApplyPlan() bool {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	leader := s.LocalNodeState().Leader
+	plan, ok := s.plans[leader]
+	if !ok {
+		return false
+	}
+
+	if plan.Leader == s.appliedPlan.Leader && plan.Version <= s.appliedPlan.Version {
+		return false
+	}
+
+	workers := plan.Assignments[s.localNodeID]
+	if workers == nil {
+		workers = make([]string, 0)
+	}
+
+	epochs := make(map[string]uint64, len(workers))
+	for _, worker := range workers {
+		epochs[worker] = plan.Epochs[worker]
+	}
+
 	ns := s.LocalNodeState()
-	ns.Workers = make([]string, 0)
-	ns.Timestamp = time.Now().UTC()
-	s.state.Nodes[s.localNodeID] = ns
+	ns.Workers = workers
+	ns.Epochs = epochs
+	s.setLocalNodeState(ns)
+	s.appliedPlan = plan
+	s.ownedAt = time.Now()
+
+	return true
+}
+
+// LocalEpochs returns ownership epochs of workers assigned to LocalNode
+func (s *StateManager) LocalEpochs() map[string]uint64 {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	epochs := make(map[string]uint64, len(s.LocalNodeState().Epochs))
+	for worker, epoch := range s.LocalNodeState().Epochs {
+		epochs[worker] = epoch
+	}
+
+	return epochs
+}
+
+func (s *StateManager) LocalWorkers() []string {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	workers := s.LocalNodeState().Workers
+	names := make([]string, len(workers))
+	copy(names, workers)
+
+	return names
 }
 
 func (s *StateManager) StartWorkers() {
@@ -225,8 +427,7 @@ func (s *StateManager) StartWorkers() {
 
 	ns := s.LocalNodeState()
 	ns.Working = isWorking
-	ns.Timestamp = time.Now().UTC()
-	s.state.Nodes[s.localNodeID] = ns
+	s.setLocalNodeState(ns)
 }
 
 func (s *StateManager) StopWorkers() {
@@ -243,8 +444,7 @@ func (s *StateManager) StopWorkers() {
 
 	ns := s.LocalNodeState()
 	ns.Working = false
-	ns.Timestamp = time.Now().UTC()
-	s.state.Nodes[s.localNodeID] = ns
+	s.setLocalNodeState(ns)
 }
 
 func (s *StateManager) Size() int {
@@ -254,14 +454,215 @@ func (s *StateManager) Size() int {
 	return len(s.state.Nodes)
 }
 
-func (s *StateManager) getLocalNodeIndex() int {
-	for index, id := range s.state.Indexes {
-		if s.localNodeID == id {
-			return index
+// IsSticky returns true when AssignmentStrategy builds on the previous assignment
+func (s *StateManager) IsSticky() bool {
+	_, ok := s.strategy.(StickyAssignmentStrategy)
+	return ok
+}
+
+// previousAssignment returns workers currently assigned to each of the nodes
+func (s *StateManager) previousAssignment() map[uint16][]string {
+	previous := make(map[uint16][]string, len(s.state.Nodes))
+	for id, node := range s.state.Nodes {
+		previous[id] = node.Workers
+	}
+
+	return previous
+}
+
+// LatestPlan returns the newest AssignmentPlan computed by LocalNode
+func (s *StateManager) LatestPlan() (AssignmentPlan, bool) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	plan, ok := s.plans[s.localNodeID]
+
+	return plan, ok
+}
+
+// IsApplied returns true when LocalNode works by the plan
+func (s *StateManager) IsApplied(plan AssignmentPlan) bool {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	return plan.Leader == s.appliedPlan.Leader && plan.Version == s.appliedPlan.Version
+}
+
+// AppliedPlan returns the AssignmentPlan LocalNode works by
+func (s *StateManager) AppliedPlan() AssignmentPlan {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	return s.appliedPlan
+}
+
+// previousOwners returns current owner of each worker, the applied AssignmentPlan takes precedence over NodeState
+func (s *StateManager) previousOwners() map[string]uint16 {
+	owners := make(map[string]uint16)
+	for id, node := range s.state.Nodes {
+		for _, worker := range node.Workers {
+			owners[worker] = id
+		}
+	}
+
+	for id, workers := range s.appliedPlan.Assignments {
+		for _, worker := range workers {
+			owners[worker] = id
+		}
+	}
+
+	return owners
+}
+
+// WorkerEpoch returns current owner & ownership epoch of the worker, as known to LocalNode
+func (s *StateManager) WorkerEpoch(worker string) (uint16, uint64) {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	var owner uint16
+	for id, workers := range s.appliedPlan.Assignments {
+		for _, name := range workers {
+			if name == worker {
+				owner = id
+			}
+		}
+	}
+
+	return owner, s.knownEpochs()[worker]
+}
+
+// nextEpochs bumps epoch of every worker whose owner changed
+func (s *StateManager) nextEpochs(assignment map[uint16][]string, previous map[string]uint16) map[string]uint64 {
+	known := s.knownEpochs()
+	epochs := make(map[string]uint64)
+	for id, workers := range assignment {
+		for _, worker := range workers {
+			epoch := known[worker]
+			if owner, ok := previous[worker]; !ok || owner != id || epoch == 0 {
+				epoch++
+			}
+
+			epochs[worker] = epoch
+		}
+	}
+
+	return epochs
+}
+
+// knownEpochs returns the highest ownership epoch of each worker from the applied AssignmentPlan & NodeState
+func (s *StateManager) knownEpochs() map[string]uint64 {
+	epochs := make(map[string]uint64)
+	for worker, epoch := range s.appliedPlan.Epochs {
+		epochs[worker] = epoch
+	}
+
+	for _, node := range s.state.Nodes {
+		for worker, epoch := range node.Epochs {
+			if epoch > epochs[worker] {
+				epochs[worker] = epoch
+			}
+		}
+	}
+
+	return epochs
+}
+
+// preferredCandidate returns the target of the leadership transfer of the term, otherwise the node with the highest
+// Config.LeaderPriority, ties are broken by the smallest Config.NodeID.
+// Nodes marked as never-leader & nodes suspected of being wedged are not eligible.
+func (s *StateManager) preferredCandidate(term uint64) (uint16, bool) {
+	if target, ok := s.transferTarget(term); ok {
+		return target, true
+	}
+
+	var candidate uint16
+	var priority int
+	var ok bool
+	for id := range s.state.Nodes {
+		meta := s.meta[id]
+		if meta.NeverLeader || s.isSuspected(id) {
+			continue
+		}
+
+		if !ok || meta.Priority > priority || (meta.Priority == priority && id < candidate) {
+			candidate = id
+			priority = meta.Priority
+			ok = true
+		}
+	}
+
+	return candidate, ok
+}
+
+// transferTarget returns the eligible target of the leadership transfer gossiped by any node for the term
+func (s *StateManager) transferTarget(term uint64) (uint16, bool) {
+	for _, node := range s.state.Nodes {
+		if node.Transfer == nil || node.Transfer.Term != term {
+			continue
+		}
+
+		target := node.Transfer.Target
+		if _, ok := s.state.Nodes[target]; ok && !s.meta[target].NeverLeader && !s.isSuspected(target) {
+			return target, true
+		}
+	}
+
+	return 0, false
+}
+
+func (s *StateManager) latestTerm() uint64 {
+	var term uint64
+	for _, node := range s.state.Nodes {
+		if node.Term > term {
+			term = node.Term
 		}
 	}
 
-	return -1
+	return term
+}
+
+// quorumSize returns configured quorum capped by the number of nodes, majority of nodes by default
+func (s *StateManager) quorumSize() int {
+	nodes := len(s.state.Nodes)
+	if s.quorum > 0 && s.quorum < nodes {
+		return s.quorum
+	}
+
+	if s.quorum > 0 {
+		return nodes
+	}
+
+	return nodes/2 + 1
+}
+
+func (s *StateManager) latestPlanVersion() uint64 {
+	var version uint64
+	for _, plan := range s.plans {
+		if plan.Version > version {
+			version = plan.Version
+		}
+	}
+
+	return version
+}
+
+// assignmentNodes returns sorted nodes with weights & tags advertised in their NodeMeta
+func (s *StateManager) assignmentNodes() []AssignmentNode {
+	nodes := make([]AssignmentNode, len(s.state.Indexes))
+	for key, id := range s.state.Indexes {
+		weight := defaultNodeWeight
+		if meta, ok := s.meta[id]; ok && meta.Weight > 0 {
+			weight = meta.Weight
+		}
+
+		nodes[key] = AssignmentNode{
+			ID:     id,
+			Weight: weight,
+			Tags:   s.meta[id].Tags,
+		}
+	}
+
+	return nodes
 }
 
 func (s *StateManager) setIndexes() {
@@ -283,9 +684,15 @@ func (s *StateManager) setIndexes() {
 	s.state.Indexes = indexes
 }
 
+// setLocalNodeState stores changed LocalNode state with the current Clock
+func (s *StateManager) setLocalNodeState(ns NodeState) {
+	ns.Clock = s.clock.Now()
+	ns.Timestamp = time.Now().UTC()
+	s.state.Nodes[s.localNodeID] = ns
+}
+
 func (s *StateManager) setCurrentState() {
 	ns := s.LocalNodeState()
 	ns.State = s.fsm.Current()
-	ns.Timestamp = time.Now().UTC()
-	s.state.Nodes[s.localNodeID] = ns
+	s.setLocalNodeState(ns)
 }
@@ -0,0 +1,117 @@
+package gossip
+
+import (
+	"math"
+	"sort"
+)
+
+type (
+	// StickyStrategy starts from the previous assignment: workers stay with their owners, only orphaned workers
+	// and the surplus of overloaded nodes move. Node's share of workers is proportional to its NodeMeta.Weight.
+	StickyStrategy struct{}
+)
+
+func (s *StickyStrategy) Assign(nodes []AssignmentNode, workers []string) map[uint16][]string {
+	return s.Reassign(nodes, workers, nil)
+}
+
+func (s *StickyStrategy) Reassign(nodes []AssignmentNode, workers []string, previous map[uint16][]string) map[uint16][]string {
+	assignment := newAssignment(nodes)
+	if len(nodes) == 0 {
+		return assignment
+	}
+
+	valid := make(map[string]bool, len(workers))
+	for _, worker := range workers {
+		valid[worker] = true
+	}
+
+	// keep previous owners, worker claimed by more than one node stays with the one with the smallest ID
+	claimed := make(map[string]bool, len(workers))
+	for _, node := range nodes {
+		kept := make([]string, 0)
+		for _, worker := range previous[node.ID] {
+			if valid[worker] && !claimed[worker] {
+				claimed[worker] = true
+				kept = append(kept, worker)
+			}
+		}
+		sort.Strings(kept)
+		assignment[node.ID] = kept
+	}
+
+	// release surplus of overloaded nodes
+	quotas := stickyQuotas(nodes, len(workers))
+	orphans := make([]string, 0)
+	for _, node := range nodes {
+		kept := assignment[node.ID]
+		if surplus := len(kept) - quotas[node.ID]; surplus > 0 {
+			orphans = append(orphans, kept[len(kept)-surplus:]...)
+			assignment[node.ID] = kept[:len(kept)-surplus]
+		}
+	}
+
+	for _, worker := range workers {
+		if !claimed[worker] {
+			orphans = append(orphans, worker)
+		}
+	}
+	sort.Strings(orphans)
+
+	// hand orphans to nodes with the biggest deficit
+	for _, worker := range orphans {
+		owner := nodes[0].ID
+		deficit := math.MinInt32
+		for _, node := range nodes {
+			if d := quotas[node.ID] - len(assignment[node.ID]); d > deficit {
+				deficit = d
+				owner = node.ID
+			}
+		}
+
+		assignment[owner] = append(assignment[owner], worker)
+	}
+
+	return assignment
+}
+
+// stickyQuotas splits total workers between nodes proportionally to their weights,
+// remainder goes to the nodes with the largest fractional share, ties are broken by node ID.
+func stickyQuotas(nodes []AssignmentNode, total int) map[uint16]int {
+	var sum float64
+	for _, node := range nodes {
+		sum += node.Weight
+	}
+
+	type share struct {
+		id       uint16
+		fraction float64
+	}
+
+	quotas := make(map[uint16]int, len(nodes))
+	shares := make([]share, len(nodes))
+	assigned := 0
+	for key, node := range nodes {
+		exact := float64(total) * node.Weight / sum
+		quotas[node.ID] = int(math.Floor(exact))
+		assigned += quotas[node.ID]
+		shares[key] = share{id: node.ID, fraction: exact - math.Floor(exact)}
+	}
+
+	sort.SliceStable(shares,
+		func(i, j int) bool {
+			if shares[i].fraction == shares[j].fraction {
+				return shares[i].id < shares[j].id
+			}
+
+			return shares[i].fraction > shares[j].fraction
+		},
+	)
+
+	for i := 0; assigned < total; i++ {
+		quotas[shares[i%len(shares)].id]++
+		assigned++
+	}
+
+	return quotas
+}
@@ -0,0 +1,128 @@
+package gossip
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStickyQuotas(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes []AssignmentNode
+		total int
+		want  map[uint16]int
+	}{
+		{
+			name:  "even split",
+			nodes: testNodes(1, 1, 1),
+			total: 9,
+			want:  map[uint16]int{1: 3, 2: 3, 3: 3},
+		},
+		{
+			name:  "remainder to the smallest IDs on ties",
+			nodes: testNodes(1, 1, 1),
+			total: 10,
+			want:  map[uint16]int{1: 4, 2: 3, 3: 3},
+		},
+		{
+			name:  "proportional to weights",
+			nodes: testNodes(1, 2, 3),
+			total: 12,
+			want:  map[uint16]int{1: 2, 2: 4, 3: 6},
+		},
+		{
+			name:  "remainder to the largest fraction",
+			nodes: testNodes(1, 3),
+			total: 3,
+			want:  map[uint16]int{1: 1, 2: 2},
+		},
+		{
+			name:  "fewer workers than nodes",
+			nodes: testNodes(1, 1, 1),
+			total: 1,
+			want:  map[uint16]int{1: 1, 2: 0, 3: 0},
+		},
+		{
+			name:  "no workers",
+			nodes: testNodes(1, 1),
+			total: 0,
+			want:  map[uint16]int{1: 0, 2: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stickyQuotas(tt.nodes, tt.total); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("stickyQuotas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStickyStrategy_Reassign(t *testing.T) {
+	s := &StickyStrategy{}
+	workers := []string{"a", "b", "c", "d", "e", "f"}
+
+	tests := []struct {
+		name     string
+		nodes    []AssignmentNode
+		workers  []string
+		previous map[uint16][]string
+		want     map[uint16][]string
+	}{
+		{
+			name:    "initial assignment",
+			nodes:   testNodes(1, 1, 1),
+			workers: workers,
+			want:    map[uint16][]string{1: {"a", "d"}, 2: {"b", "e"}, 3: {"c", "f"}},
+		},
+		{
+			name:     "balanced assignment is kept",
+			nodes:    testNodes(1, 1, 1),
+			workers:  workers,
+			previous: map[uint16][]string{1: {"c", "f"}, 2: {"a", "e"}, 3: {"b", "d"}},
+			want:     map[uint16][]string{1: {"c", "f"}, 2: {"a", "e"}, 3: {"b", "d"}},
+		},
+		{
+			name:     "joined node takes only the surplus",
+			nodes:    testNodes(1, 1, 1),
+			workers:  workers,
+			previous: map[uint16][]string{1: {"a", "b", "c"}, 2: {"d", "e", "f"}},
+			want:     map[uint16][]string{1: {"a", "b"}, 2: {"d", "e"}, 3: {"c", "f"}},
+		},
+		{
+			name:     "workers of the left node are orphaned",
+			nodes:    testNodes(1, 1),
+			workers:  workers,
+			previous: map[uint16][]string{1: {"a", "d"}, 2: {"b", "e"}, 3: {"c", "f"}},
+			want:     map[uint16][]string{1: {"a", "d", "c"}, 2: {"b", "e", "f"}},
+		},
+		{
+			name:     "duplicate claim goes to the smallest ID & removed workers are dropped",
+			nodes:    testNodes(1, 1),
+			workers:  []string{"a", "b"},
+			previous: map[uint16][]string{1: {"a", "x"}, 2: {"a", "b"}},
+			want:     map[uint16][]string{1: {"a"}, 2: {"b"}},
+		},
+		{
+			name:     "heavier node gets the bigger share",
+			nodes:    testNodes(1, 2),
+			workers:  workers,
+			previous: map[uint16][]string{1: {"a", "b", "c"}, 2: {"d", "e", "f"}},
+			want:     map[uint16][]string{1: {"a", "b"}, 2: {"d", "e", "f", "c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.Reassign(tt.nodes, tt.workers, tt.previous)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Reassign() = %v, want %v", got, tt.want)
+			}
+
+			if len(owners(t, got)) != len(tt.workers) {
+				t.Fatalf("%d workers assigned, want %d", len(owners(t, got)), len(tt.workers))
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package gossip
+
+import (
+	"encoding/json"
+	"go.uber.org/zap"
+	"sync/atomic"
+)
+
+type (
+	// SyncState is exchanged on TCP push/pull & join. By default only Digest is exchanged, each side then sends
+	// entries the other one is missing as a direct state delta message. With Config.FullStateSync
+	// the state carries everything the node knows, so that any node converges from any peer.
+	// Tombstones are always sent in full, merging them is idempotent.
+	SyncState struct {
+		Digest     *StateDigest         `json:"digest,omitempty"`
+		Nodes      map[uint16]NodeState `json:"nodes,omitempty"`
+		Tombstones map[uint16]Tombstone `json:"tombstones,omitempty"`
+		KV         []KVEntry            `json:"kv,omitempty"`
+		CRDT       []CRDTState          `json:"crdt,omitempty"`
+		Registry   WorkerRegistry       `json:"registry"`
+	}
+
+	// StateDigest is the compact summary of the state known to NodeID: clocks of node entries, registry version,
+	// versions of KV keys & hashes of CRDT states
+	StateDigest struct {
+		NodeID         uint16            `json:"node_id"`
+		Nodes          map[uint16]HLC    `json:"nodes"`
+		Registry       uint64            `json:"registry"`
+		RegistryNodeID uint16            `json:"registry_node_id"`
+		KV             map[string]HLC    `json:"kv,omitempty"`
+		CRDT           map[string]uint64 `json:"crdt,omitempty"`
+	}
+
+	// SyncMetrics counts bytes of the state sync, Saved is the difference between the full states that would have
+	// been exchanged & digests plus deltas actually sent. In digest mode FullBytes is an estimate, the full state
+	// is measured every fullStateSampling exchanges & its last size is counted for each exchange
+	SyncMetrics struct {
+		Exchanges   uint64 `json:"exchanges"`
+		DigestBytes uint64 `json:"digest_bytes"`
+		DeltaBytes  uint64 `json:"delta_bytes"`
+		FullBytes   uint64 `json:"full_bytes"`
+		Saved       int64  `json:"saved"`
+	}
+
+	syncCounters struct {
+		exchanges   uint64
+		digestBytes uint64
+		deltaBytes  uint64
+		fullBytes   uint64
+		fullSize    uint64
+	}
+)
+
+// fullStateSampling is how often, in exchanges, the full state is marshalled to estimate its size
+const fullStateSampling = 16
+
+// SyncMetrics returns bytes of the state sync sent by the local node
+func (c *Cluster) SyncMetrics() SyncMetrics {
+	return c.syncCounters.snapshot()
+}
+
+// Digest returns clocks of all known node states
+func (s *StateManager) Digest() map[uint16]HLC {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	nodes := make(map[uint16]HLC, len(s.state.Nodes))
+	for key, node := range s.state.Nodes {
+		nodes[key] = node.Clock
+	}
+
+	return nodes
+}
+
+// Delta returns node states with clocks different from the digest, or missing from it
+func (s *StateManager) Delta(digest map[uint16]HLC) map[uint16]NodeState {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	nodes := make(map[uint16]NodeState)
+	for key, node := range s.state.Nodes {
+		if clock, ok := digest[key]; !ok || node.Clock != clock {
+			nodes[key] = node
+		}
+	}
+
+	return nodes
+}
+
+// syncState returns SyncState sent on push/pull, digest only unless Config.FullStateSync is set
+func (d *Delegate) syncState() SyncState {
+	if d.fullSync {
+		return d.fullState()
+	}
+
+	registry := d.Workers.Registry()
+	return SyncState{
+		Tombstones: d.State.Tombstones(),
+		Digest: &StateDigest{
+			NodeID:         d.nm.NodeID,
+			Nodes:          d.State.Digest(),
+			Registry:       registry.Version,
+			RegistryNodeID: registry.NodeID,
+			KV:             d.KV.Digest(),
+			CRDT:           d.CRDT.Digest(),
+		},
+	}
+}
+
+func (d *Delegate) fullState() SyncState {
+	return SyncState{
+		Nodes:      d.State.FullState(),
+		Tombstones: d.State.Tombstones(),
+		KV:         d.KV.Entries(),
+		CRDT:       d.CRDT.States(),
+		Registry:   d.Workers.Registry(),
+	}
+}
+
+// mergeSyncState imports full state, or answers the digest with the delta of entries the peer is missing
+func (d *Delegate) mergeSyncState(state SyncState) {
+	d.State.ImportTombstones(state.Tombstones)
+
+	if state.Digest == nil {
+		d.State.ImportState(state.Nodes)
+		d.KV.Merge(state.KV)
+		d.CRDT.Merge(state.CRDT)
+		d.Workers.Merge(state.Registry)
+		return
+	}
+
+	delta := SyncState{
+		Nodes: d.State.Delta(state.Digest.Nodes),
+		KV:    d.KV.Delta(state.Digest.KV),
+		CRDT:  d.CRDT.Delta(state.Digest.CRDT),
+	}
+	peerRegistry := WorkerRegistry{
+		Version: state.Digest.Registry,
+		NodeID:  state.Digest.RegistryNodeID,
+	}
+	if registry := d.Workers.Registry(); registry.newerThan(peerRegistry) {
+		delta.Registry = registry
+	}
+
+	if len(delta.Nodes) == 0 && len(delta.KV) == 0 && len(delta.CRDT) == 0 && delta.Registry.Version == 0 {
+		return
+	}
+
+	data, err := newEnvelope(stateDeltaMessage, d.nm.NodeID, delta)
+	if err != nil {
+		d.logger.Error("gossip.Delegate.mergeSyncState()", zap.Error(err))
+		return
+	}
+	atomic.AddUint64(&d.syncCounters.deltaBytes, uint64(len(data)))
+
+	go func() {
+		if err := d.Messenger.SendToNode(state.Digest.NodeID, data); err != nil {
+			d.logger.Warn("gossip.Delegate.mergeSyncState()",
+				zap.Uint16("node", state.Digest.NodeID),
+				zap.Error(err))
+		}
+	}()
+}
+
+// countSync counts sent state, full state size is sampled on the first & every fullStateSampling exchange
+func (d *Delegate) countSync(state SyncState, size int) {
+	exchanges := atomic.AddUint64(&d.syncCounters.exchanges, 1)
+	if state.Digest == nil {
+		atomic.AddUint64(&d.syncCounters.fullBytes, uint64(size))
+		return
+	}
+
+	atomic.AddUint64(&d.syncCounters.digestBytes, uint64(size))
+	if exchanges%fullStateSampling == 1 {
+		if full, err := json.Marshal(d.fullState()); err == nil {
+			atomic.StoreUint64(&d.syncCounters.fullSize, uint64(len(full)))
+		}
+	}
+	atomic.AddUint64(&d.syncCounters.fullBytes, atomic.LoadUint64(&d.syncCounters.fullSize))
+}
+
+// onStateDelta imports entries the peer found missing or outdated in the local digest
+func (c *Cluster) onStateDelta(msg Envelope) error {
+	var delta SyncState
+	if err := msg.Decode(&delta); err != nil {
+		return err
+	}
+
+	c.State.ImportState(delta.Nodes)
+	c.KV.Merge(delta.KV)
+	c.CRDT.Merge(delta.CRDT)
+	c.Workers.Merge(delta.Registry)
+
+	return nil
+}
+
+func (sc *syncCounters) snapshot() SyncMetrics {
+	m := SyncMetrics{
+		Exchanges:   atomic.LoadUint64(&sc.exchanges),
+		DigestBytes: atomic.LoadUint64(&sc.digestBytes),
+		DeltaBytes:  atomic.LoadUint64(&sc.deltaBytes),
+		FullBytes:   atomic.LoadUint64(&sc.fullBytes),
+	}
+	if m.FullBytes > 0 {
+		m.Saved = int64(m.FullBytes) - int64(m.DigestBytes) - int64(m.DeltaBytes)
+	}
+
+	return m
+}
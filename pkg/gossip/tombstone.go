@@ -0,0 +1,115 @@
+package gossip
+
+import (
+	"time"
+)
+
+type (
+	// Tombstone marks node removed from the cluster, entries of its Generation or older are rejected until
+	// the tombstone expires TTL after its Clock. Tombstones are gossiped with the state, so that a departed node
+	// is not re-added by peers that still have its entry. Node that genuinely rejoins starts with newer Generation.
+	Tombstone struct {
+		NodeID     uint16 `json:"node_id"`
+		Generation uint64 `json:"generation"`
+		Clock      HLC    `json:"clock"`
+	}
+)
+
+// Tombstones returns copy of tombstones that have not expired yet
+func (s *StateManager) Tombstones() map[uint16]Tombstone {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	s.pruneTombstones()
+
+	tombstones := make(map[uint16]Tombstone, len(s.tombstones))
+	for key, t := range s.tombstones {
+		tombstones[key] = t
+	}
+
+	return tombstones
+}
+
+// ImportTombstones merges tombstones received from a peer & removes entries of the buried node generations.
+// Tombstone of the LocalNode, e.g. after it was falsely declared dead, moves its Generation past it.
+func (s *StateManager) ImportTombstones(tombstones map[uint16]Tombstone) {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	var removed bool
+	for key, t := range tombstones {
+		if t.expired(s.tombstoneTTL) {
+			continue
+		}
+		s.clock.Update(t.Clock)
+
+		if key == s.localNodeID {
+			if ns := s.LocalNodeState(); t.Generation >= ns.Generation {
+				ns.Generation = t.Generation + 1
+				s.setLocalNodeState(ns)
+			}
+			continue
+		}
+
+		if current, ok := s.tombstones[key]; ok && current.Generation >= t.Generation {
+			continue
+		}
+		s.tombstones[key] = t
+
+		if node, ok := s.state.Nodes[key]; ok && node.Generation <= t.Generation {
+			s.removeNode(key)
+			removed = true
+		}
+	}
+
+	if removed {
+		s.setIndexes()
+	}
+}
+
+// isBuried returns true when the node entry belongs to a generation removed from the cluster
+func (s *StateManager) isBuried(id uint16, node NodeState) bool {
+	t, ok := s.tombstones[id]
+	if !ok {
+		return false
+	}
+
+	if node.Generation > t.Generation {
+		delete(s.tombstones, id)
+		return false
+	}
+
+	return true
+}
+
+// bury records tombstone of the node entry being removed
+func (s *StateManager) bury(id uint16, node NodeState) {
+	if t, ok := s.tombstones[id]; ok && t.Generation >= node.Generation {
+		return
+	}
+
+	s.tombstones[id] = Tombstone{
+		NodeID:     id,
+		Generation: node.Generation,
+		Clock:      s.clock.Now(),
+	}
+}
+
+func (s *StateManager) pruneTombstones() {
+	for key, t := range s.tombstones {
+		if t.expired(s.tombstoneTTL) {
+			delete(s.tombstones, key)
+		}
+	}
+}
+
+// expired is evaluated against Clock of the tombstone, so that all nodes drop it at about the same time
+// & it does not bounce between peers
+func (t Tombstone) expired(ttl time.Duration) bool {
+	return time.Since(t.Clock.Wall()) > ttl
+}
+
+// newGeneration returns generation of the node incarnation, it grows with every restart of the node
+func newGeneration() uint64 {
+	return uint64(time.Now().UnixNano())
+}
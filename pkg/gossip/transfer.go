@@ -0,0 +1,224 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/memberlist"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrUnknownNode     = errors.New("unknown node")
+	ErrNotEligible     = errors.New("node is not eligible for leadership")
+	ErrNoLeader        = errors.New("cluster has no leader")
+	ErrInvalidNodeAddr = errors.New("invalid node address")
+)
+
+type (
+	// LeadershipTransfer is announced by the leader stepping down in favor of Target, Term is the new election term.
+	LeadershipTransfer struct {
+		Leader uint16 `json:"leader"`
+		Target uint16 `json:"target"`
+		Term   uint64 `json:"term"`
+	}
+
+	// LeadershipTransferRequest asks the leader to transfer leadership to Target.
+	LeadershipTransferRequest struct {
+		Target uint16 `json:"target"`
+	}
+)
+
+// TransferLeadership makes the current leader step down in favor of the target node & waits until the target
+// is elected. Called on a follower, the request is forwarded to the leader.
+func (c *Cluster) TransferLeadership(ctx context.Context, target uint16) error {
+	if !c.Messenger.Ready() {
+		return ErrClusterNotReady
+	}
+
+	if err := c.State.CanLead(target); err != nil {
+		return fmt.Errorf("gossip.Cluster.TransferLeadership(): %w", err)
+	}
+
+	leader, _ := c.State.Leader()
+	switch {
+	case leader == target:
+		return nil
+	case leader == 0:
+		return fmt.Errorf("gossip.Cluster.TransferLeadership(): %w", ErrNoLeader)
+	case leader == c.Config.NodeID:
+		transfer := c.State.TransferTo(target, 0)
+		if err := c.Messenger.SendLeadershipTransfer(transfer); err != nil {
+			return fmt.Errorf("gossip.Cluster.TransferLeadership(): %w", err)
+		}
+
+		c.reelect()
+	default:
+		if err := c.Messenger.SendLeadershipTransferRequest(leader, target); err != nil {
+			return fmt.Errorf("gossip.Cluster.TransferLeadership(): %w", err)
+		}
+	}
+
+	for {
+		if leader, _ = c.State.Leader(); leader == target && isSettled(c.State.CurrentState()) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gossip.Cluster.TransferLeadership(): %w", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// onLeadershipTransfer joins the election announced by the current leader stepping down
+func (c *Cluster) onLeadershipTransfer(msg Envelope) error {
+	var transfer LeadershipTransfer
+	if err := msg.Decode(&transfer); err != nil {
+		return err
+	}
+
+	if leader, _ := c.State.Leader(); msg.Sender != leader || transfer.Leader != leader {
+		c.logger.Warn("gossip.Cluster.onLeadershipTransfer(), not sent by the current leader",
+			zap.Uint16("sender", msg.Sender),
+			zap.Uint16("target", transfer.Target))
+		return nil
+	}
+
+	c.State.TransferTo(transfer.Target, transfer.Term)
+	c.reelect()
+
+	return nil
+}
+
+// onLeadershipTransferRequest handles the request of a follower or a command line tool
+func (c *Cluster) onLeadershipTransferRequest(msg Envelope) error {
+	var request LeadershipTransferRequest
+	if err := msg.Decode(&request); err != nil {
+		return err
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.Config.AssembleTimeoutS)*time.Second)
+		defer cancel()
+
+		if err := c.TransferLeadership(ctx, request.Target); err != nil {
+			c.logger.Error("gossip.Cluster.onLeadershipTransferRequest()", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// CanLead returns error when the node is unknown or not eligible for leadership
+func (s *StateManager) CanLead(id uint16) error {
+	s.rwm.RLock()
+	defer s.rwm.RUnlock()
+
+	if _, ok := s.state.Nodes[id]; !ok {
+		return fmt.Errorf("node %d: %w", id, ErrUnknownNode)
+	}
+
+	if s.meta[id].NeverLeader {
+		return fmt.Errorf("node %d: %w", id, ErrNotEligible)
+	}
+
+	return nil
+}
+
+// TransferTo prefers target in the election of the transfer term & moves LocalNode to it, zero term starts
+// a new one. The transfer is gossiped with LocalNode state until the election of the term is finished.
+func (s *StateManager) TransferTo(target uint16, term uint64) LeadershipTransfer {
+	s.rwm.Lock()
+	defer s.rwm.Unlock()
+
+	if term == 0 {
+		term = s.latestTerm() + 1
+	}
+
+	ns := s.LocalNodeState()
+	if term > ns.Term {
+		ns.Term = term
+	}
+	ns.Transfer = &LeadershipTransfer{
+		Leader: ns.Leader,
+		Target: target,
+		Term:   term,
+	}
+	s.setLocalNodeState(ns)
+	delete(s.suspects, target)
+
+	return *ns.Transfer
+}
+
+// SendLeadershipTransfer announces to all nodes that the leader steps down
+func (m *Messenger) SendLeadershipTransfer(transfer LeadershipTransfer) error {
+	return m.SendMessageToAll(leadershipTransferMessage, transfer)
+}
+
+// SendLeadershipTransferRequest asks the leader to transfer leadership to the target node
+func (m *Messenger) SendLeadershipTransferRequest(leader, target uint16) error {
+	return m.SendMessage(leader, leadershipTransferRequestMessage, LeadershipTransferRequest{
+		Target: target,
+	})
+}
+
+// RequestLeadershipTransfer asks the cluster node listening on addr (host:port) to transfer leadership
+// to the target node. It is meant for command line tools, which are not members of the cluster:
+// a standalone memberlist bound to bindAddr is used only to deliver the request.
+func RequestLeadershipTransfer(bindAddr, addr string, target uint16) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("gossip.RequestLeadershipTransfer() '%s': %w", addr, err)
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("gossip.RequestLeadershipTransfer() '%s': %w", addr, ErrInvalidNodeAddr)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return fmt.Errorf("gossip.RequestLeadershipTransfer() '%s': %w", addr, err)
+	}
+
+	if bindAddr == "" {
+		bindAddr = "127.0.0.1"
+	}
+
+	mlc := memberlist.DefaultLANConfig()
+	mlc.Name = "transfer-leadership-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	mlc.BindAddr = bindAddr
+	mlc.AdvertiseAddr = bindAddr
+	mlc.BindPort = 0
+	mlc.LogOutput = io.Discard
+
+	ml, err := memberlist.Create(mlc)
+	if err != nil {
+		return fmt.Errorf("gossip.RequestLeadershipTransfer() memberlist.Create(): %w", err)
+	}
+	defer ml.Shutdown()
+
+	data, err := newEnvelope(leadershipTransferRequestMessage, 0, LeadershipTransferRequest{
+		Target: target,
+	})
+	if err != nil {
+		return fmt.Errorf("gossip.RequestLeadershipTransfer(): %w", err)
+	}
+
+	node := &memberlist.Node{
+		Name: addr,
+		Addr: ip,
+		Port: uint16(p),
+	}
+	if err = ml.SendReliable(node, data); err != nil {
+		return fmt.Errorf("gossip.RequestLeadershipTransfer() SendReliable(): %w", err)
+	}
+
+	return nil
+}
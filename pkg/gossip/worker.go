@@ -0,0 +1,48 @@
+package gossip
+
+import "context"
+
+type (
+	// Worker is a unit of long-running work owned by exactly one node of the cluster.
+	// Workers are registered with Cluster.RegisterWorker and started / stopped on the
+	// owning node whenever the cluster assigns or releases them.
+	// Start & Stop must not block longer than the given context allows, long-running
+	// work should be moved to a goroutine owned by the Worker.
+	// Start receives ownership epoch, it grows with every reassignment of the worker and should be
+	// used as a fencing token against downstream systems, so that writes of a stale owner can be rejected.
+	Worker interface {
+		Name() string
+		Start(ctx context.Context, epoch uint64) error
+		Stop(ctx context.Context) error
+	}
+
+	// WorkerFactory creates Worker from its configuration, it is registered per WorkerConfig.Type
+	// with Cluster.RegisterWorkerType.
+	WorkerFactory func(cfg WorkerConfig) (Worker, error)
+
+	// WorkerConfig defines a worker known to the whole cluster.
+	// Worker with Tags is assigned only to nodes that have all of them in Config.Tags.
+	WorkerConfig struct {
+		Name     string            `yaml:"name" json:"name"`
+		Type     string            `yaml:"type" json:"type"`
+		Tags     []string          `yaml:"tags" json:"tags,omitempty"`
+		Settings map[string]string `yaml:"settings" json:"settings,omitempty"`
+	}
+
+	// WorkerRegistry is the set of workers known to the cluster. It is propagated via gossip,
+	// registry with higher Version wins, NodeID of the node that made the change breaks ties.
+	WorkerRegistry struct {
+		Version uint64         `json:"version"`
+		NodeID  uint16         `json:"node_id"`
+		Workers []WorkerConfig `json:"workers"`
+	}
+)
+
+// newerThan returns true when r should replace o
+func (r WorkerRegistry) newerThan(o WorkerRegistry) bool {
+	if r.Version != o.Version {
+		return r.Version > o.Version
+	}
+
+	return r.NodeID > o.NodeID
+}
@@ -0,0 +1,390 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrWorkerNameEmpty      = errors.New("worker name is empty")
+	ErrWorkerRegistered     = errors.New("worker is already registered")
+	ErrWorkerNotFound       = errors.New("worker not found")
+	ErrWorkerTypeRegistered = errors.New("worker type is already registered")
+)
+
+type (
+	// WorkerManager holds the cluster-wide WorkerRegistry, Worker implementations & factories registered
+	// on the local node and runs the workers assigned to it.
+	WorkerManager struct {
+		logger      *zap.Logger
+		localNodeID uint16
+		registry    WorkerRegistry
+		factories   map[string]WorkerFactory
+		workers     map[string]Worker
+		created     map[string]bool
+		running     map[string]bool
+		released    map[string]map[uint16]uint64
+		changedCh   chan struct{}
+		rwm         sync.RWMutex
+	}
+)
+
+func newWorkerManager(logger *zap.Logger, localNodeID uint16, workers []WorkerConfig) *WorkerManager {
+	m := &WorkerManager{
+		logger:      logger,
+		localNodeID: localNodeID,
+		factories:   make(map[string]WorkerFactory),
+		workers:     make(map[string]Worker),
+		created:     make(map[string]bool),
+		running:     make(map[string]bool),
+		released:    make(map[string]map[uint16]uint64),
+		changedCh:   make(chan struct{}, 1),
+	}
+
+	for _, wc := range workers {
+		if wc.Name == "" || m.hasDefinition(wc.Name) {
+			logger.Warn("gossip.newWorkerManager(), invalid or duplicate worker skipped", zap.String("worker", wc.Name))
+			continue
+		}
+
+		m.registry.Workers = append(m.registry.Workers, parseWorkerDefaults(wc))
+	}
+	sortWorkerConfigs(m.registry.Workers)
+
+	return m
+}
+
+// Register adds Worker to the local node, it will be started once assigned to it.
+// Worker missing from the WorkerRegistry is added to it without bumping the Version: every node registers
+// the same workers before it joins, so their registries stay equal. Only Add & Remove change it at runtime.
+func (m *WorkerManager) Register(w Worker) error {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	name := w.Name()
+	if name == "" {
+		return ErrWorkerNameEmpty
+	}
+
+	if _, ok := m.workers[name]; ok {
+		return fmt.Errorf("gossip.WorkerManager.Register() '%s': %w", name, ErrWorkerRegistered)
+	}
+
+	m.workers[name] = w
+
+	if !m.hasDefinition(name) {
+		workers := append(m.copyDefinitions(), parseWorkerDefaults(WorkerConfig{Name: name}))
+		sortWorkerConfigs(workers)
+		m.registry.Workers = workers
+	}
+
+	return nil
+}
+
+// RegisterType registers WorkerFactory used to create workers of WorkerConfig.Type on the local node.
+func (m *WorkerManager) RegisterType(typ string, factory WorkerFactory) error {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if _, ok := m.factories[typ]; ok {
+		return fmt.Errorf("gossip.WorkerManager.RegisterType() '%s': %w", typ, ErrWorkerTypeRegistered)
+	}
+
+	m.factories[typ] = factory
+
+	return nil
+}
+
+// Add adds worker definition to the WorkerRegistry & returns the new registry.
+func (m *WorkerManager) Add(wc WorkerConfig) (WorkerRegistry, error) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if wc.Name == "" {
+		return WorkerRegistry{}, ErrWorkerNameEmpty
+	}
+
+	if m.hasDefinition(wc.Name) {
+		return WorkerRegistry{}, fmt.Errorf("gossip.WorkerManager.Add() '%s': %w", wc.Name, ErrWorkerRegistered)
+	}
+
+	workers := append(m.copyDefinitions(), parseWorkerDefaults(wc))
+	sortWorkerConfigs(workers)
+	m.setRegistry(workers)
+
+	return m.copyRegistry(), nil
+}
+
+// Remove removes worker definition from the WorkerRegistry & returns the new registry.
+func (m *WorkerManager) Remove(name string) (WorkerRegistry, error) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if !m.hasDefinition(name) {
+		return WorkerRegistry{}, fmt.Errorf("gossip.WorkerManager.Remove() '%s': %w", name, ErrWorkerNotFound)
+	}
+
+	workers := make([]WorkerConfig, 0, len(m.registry.Workers))
+	for _, wc := range m.registry.Workers {
+		if wc.Name != name {
+			workers = append(workers, wc)
+		}
+	}
+	m.setRegistry(workers)
+
+	return m.copyRegistry(), nil
+}
+
+// Merge replaces the WorkerRegistry with the remote one when it is newer, returns true if replaced.
+func (m *WorkerManager) Merge(registry WorkerRegistry) bool {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if !registry.newerThan(m.registry) {
+		return false
+	}
+
+	sortWorkerConfigs(registry.Workers)
+	m.registry = registry
+	m.notifyChanged()
+
+	return true
+}
+
+// Registry returns copy of the WorkerRegistry
+func (m *WorkerManager) Registry() WorkerRegistry {
+	m.rwm.RLock()
+	defer m.rwm.RUnlock()
+
+	return m.copyRegistry()
+}
+
+// Names returns sorted names of all workers in the WorkerRegistry.
+func (m *WorkerManager) Names() []string {
+	m.rwm.RLock()
+	defer m.rwm.RUnlock()
+
+	names := make([]string, len(m.registry.Workers))
+	for key, wc := range m.registry.Workers {
+		names[key] = wc.Name
+	}
+
+	return names
+}
+
+// Running returns sorted names of workers running on the local node.
+func (m *WorkerManager) Running() []string {
+	m.rwm.RLock()
+	defer m.rwm.RUnlock()
+
+	names := make([]string, 0, len(m.running))
+	for name := range m.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Changed returns channel signaled whenever the WorkerRegistry changes.
+func (m *WorkerManager) Changed() <-chan struct{} {
+	return m.changedCh
+}
+
+// Release stops running workers missing from names.
+// The first error is returned after all workers were processed.
+func (m *WorkerManager) Release(ctx context.Context, names []string) error {
+	assigned := make(map[string]bool, len(names))
+	for _, name := range names {
+		assigned[name] = true
+	}
+
+	var firstErr error
+	for _, name := range m.Running() {
+		if assigned[name] {
+			continue
+		}
+
+		if err := m.stopWorker(ctx, name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Start stops running workers missing from names & starts the ones from names that are not running yet
+// with their ownership epochs. Names without registered Worker or WorkerFactory are skipped.
+// The first error is returned after all workers were processed.
+func (m *WorkerManager) Start(ctx context.Context, names []string, epochs map[string]uint64) error {
+	firstErr := m.Release(ctx, names)
+	for _, name := range names {
+		if err := m.startWorker(ctx, name, epochs[name]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Stop stops all workers running on the local node.
+func (m *WorkerManager) Stop(ctx context.Context) error {
+	var firstErr error
+	for _, name := range m.Running() {
+		if err := m.stopWorker(ctx, name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (m *WorkerManager) startWorker(ctx context.Context, name string, epoch uint64) error {
+	m.rwm.RLock()
+	running := m.running[name]
+	m.rwm.RUnlock()
+
+	if running {
+		return nil
+	}
+
+	w, err := m.worker(name)
+	if err != nil {
+		m.logger.Error("gossip.WorkerManager.startWorker()", zap.String("worker", name), zap.Error(err))
+		return fmt.Errorf("gossip.WorkerManager.startWorker() '%s': %w", name, err)
+	}
+
+	if w == nil {
+		m.logger.Warn("gossip.WorkerManager.startWorker(), worker not registered", zap.String("worker", name))
+		return nil
+	}
+
+	if err = w.Start(ctx, epoch); err != nil {
+		m.logger.Error("gossip.WorkerManager.startWorker()", zap.String("worker", name), zap.Error(err))
+		return fmt.Errorf("gossip.WorkerManager.startWorker() '%s': %w", name, err)
+	}
+
+	m.rwm.Lock()
+	m.running[name] = true
+	m.rwm.Unlock()
+
+	return nil
+}
+
+func (m *WorkerManager) stopWorker(ctx context.Context, name string) error {
+	m.rwm.RLock()
+	w, ok := m.workers[name]
+	m.rwm.RUnlock()
+
+	if ok {
+		if err := w.Stop(ctx); err != nil {
+			m.logger.Error("gossip.WorkerManager.stopWorker()", zap.String("worker", name), zap.Error(err))
+			return fmt.Errorf("gossip.WorkerManager.stopWorker() '%s': %w", name, err)
+		}
+	}
+
+	m.rwm.Lock()
+	delete(m.running, name)
+	if m.created[name] {
+		delete(m.workers, name)
+		delete(m.created, name)
+	}
+	m.rwm.Unlock()
+
+	return nil
+}
+
+// worker returns registered Worker, or creates one with WorkerFactory of its WorkerConfig.Type,
+// created workers are kept only while running. Returns nil Worker when neither is registered on the local node.
+func (m *WorkerManager) worker(name string) (Worker, error) {
+	m.rwm.Lock()
+	defer m.rwm.Unlock()
+
+	if w, ok := m.workers[name]; ok {
+		return w, nil
+	}
+
+	var wc WorkerConfig
+	var ok bool
+	for _, def := range m.registry.Workers {
+		if def.Name == name {
+			wc, ok = def, true
+			break
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	factory, ok := m.factories[wc.Type]
+	if !ok {
+		return nil, nil
+	}
+
+	w, err := factory(wc)
+	if err != nil {
+		return nil, err
+	}
+	m.workers[name] = w
+	m.created[name] = true
+
+	return w, nil
+}
+
+func (m *WorkerManager) hasDefinition(name string) bool {
+	for _, wc := range m.registry.Workers {
+		if wc.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *WorkerManager) setRegistry(workers []WorkerConfig) {
+	m.registry = WorkerRegistry{
+		Version: m.registry.Version + 1,
+		NodeID:  m.localNodeID,
+		Workers: workers,
+	}
+	m.notifyChanged()
+}
+
+func (m *WorkerManager) copyDefinitions() []WorkerConfig {
+	workers := make([]WorkerConfig, len(m.registry.Workers))
+	copy(workers, m.registry.Workers)
+
+	return workers
+}
+
+func (m *WorkerManager) copyRegistry() WorkerRegistry {
+	registry := m.registry
+	registry.Workers = m.copyDefinitions()
+
+	return registry
+}
+
+func (m *WorkerManager) notifyChanged() {
+	select {
+	case m.changedCh <- struct{}{}:
+	default:
+	}
+}
+
+func parseWorkerDefaults(wc WorkerConfig) WorkerConfig {
+	sort.Strings(wc.Tags)
+
+	return wc
+}
+
+func sortWorkerConfigs(workers []WorkerConfig) {
+	sort.Slice(workers,
+		func(i, j int) bool {
+			return workers[i].Name < workers[j].Name
+		},
+	)
+}
@@ -0,0 +1,77 @@
+package gossip
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"reflect"
+	"testing"
+)
+
+type testWorker struct {
+	name string
+}
+
+func (w *testWorker) Name() string {
+	return w.name
+}
+
+func (w *testWorker) Start(ctx context.Context, epoch uint64) error {
+	return nil
+}
+
+func (w *testWorker) Stop(ctx context.Context) error {
+	return nil
+}
+
+func TestWorkerRegistry_newerThan(t *testing.T) {
+	tests := []struct {
+		name string
+		r    WorkerRegistry
+		o    WorkerRegistry
+		want bool
+	}{
+		{name: "higher version", r: WorkerRegistry{Version: 2, NodeID: 1}, o: WorkerRegistry{Version: 1, NodeID: 2}, want: true},
+		{name: "lower version", r: WorkerRegistry{Version: 1, NodeID: 2}, o: WorkerRegistry{Version: 2, NodeID: 1}, want: false},
+		{name: "tie, bigger node", r: WorkerRegistry{Version: 1, NodeID: 2}, o: WorkerRegistry{Version: 1, NodeID: 1}, want: true},
+		{name: "tie, smaller node", r: WorkerRegistry{Version: 1, NodeID: 1}, o: WorkerRegistry{Version: 1, NodeID: 2}, want: false},
+		{name: "same registry", r: WorkerRegistry{Version: 1, NodeID: 1}, o: WorkerRegistry{Version: 1, NodeID: 1}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.newerThan(tt.o); got != tt.want {
+				t.Fatalf("newerThan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkerManager_Register(t *testing.T) {
+	m := newWorkerManager(zap.NewNop(), 1, []WorkerConfig{{Name: "b"}})
+	if err := m.Register(&testWorker{name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := m.Registry()
+	if registry.Version != 0 || registry.NodeID != 0 {
+		t.Fatalf("Register() changed registry version to %d/%d", registry.Version, registry.NodeID)
+	}
+
+	if names := m.Names(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("Names() = %v, want [a b]", names)
+	}
+
+	select {
+	case <-m.Changed():
+		t.Fatal("Register() notified registry change")
+	default:
+	}
+
+	if _, err := m.Add(WorkerConfig{Name: "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if registry = m.Registry(); registry.Version != 1 || registry.NodeID != 1 {
+		t.Fatalf("Add() registry version = %d/%d, want 1/1", registry.Version, registry.NodeID)
+	}
+}